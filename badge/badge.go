@@ -0,0 +1,125 @@
+// Package badge renders shields style coverage badges as SVG.
+package badge
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// ColorStop colors a badge with Color when coverage is at least Threshold.
+type ColorStop struct {
+	Threshold float64 `mapstructure:"threshold"`
+	Color     string  `mapstructure:"color"`
+}
+
+// DefaultColors is the color scale used by shields.io for coverage.
+var DefaultColors = []ColorStop{
+	{Threshold: 90, Color: "#4c1"},
+	{Threshold: 80, Color: "#97ca00"},
+	{Threshold: 70, Color: "#a4a61d"},
+	{Threshold: 60, Color: "#dfb317"},
+	{Threshold: 50, Color: "#fe7d37"},
+	{Threshold: 0, Color: "#e05d44"},
+}
+
+// Color returns the color of the highest stop coverage reaches, or the color
+// of the lowest stop if it reaches none.
+func Color(coverage float64, scale []ColorStop) string {
+	if len(scale) == 0 {
+		scale = DefaultColors
+	}
+	stops := make([]ColorStop, len(scale))
+	copy(stops, scale)
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].Threshold > stops[j].Threshold
+	})
+	for _, stop := range stops {
+		if coverage >= stop.Threshold {
+			return stop.Color
+		}
+	}
+	return stops[len(stops)-1].Color
+}
+
+// Badge is a flat shields style badge.
+type Badge struct {
+	Label string
+	Value string
+	Color string
+}
+
+// Coverage creates a badge of the coverage percentage colored by scale.
+func Coverage(label string, coverage float64, scale []ColorStop) *Badge {
+	return &Badge{
+		Label: label,
+		Value: fmt.Sprintf("%.2f%%", coverage),
+		Color: Color(coverage, scale),
+	}
+}
+
+// WriteSVG writes the badge as a standalone SVG document.
+func (b *Badge) WriteSVG(w io.Writer) error {
+	labelWidth := textWidth(b.Label) + 2*padding
+	valueWidth := textWidth(b.Value) + 2*padding
+	return svgTemplate.Execute(w, map[string]interface{}{
+		"Label":      b.Label,
+		"Value":      b.Value,
+		"Color":      b.Color,
+		"LabelWidth": labelWidth,
+		"ValueWidth": valueWidth,
+		"Width":      labelWidth + valueWidth,
+		"LabelX":     labelWidth * 10 / 2,
+		"ValueX":     (labelWidth + valueWidth/2) * 10,
+		"LabelLen":   (labelWidth - 2*padding) * 10,
+		"ValueLen":   (valueWidth - 2*padding) * 10,
+	})
+}
+
+const padding = 5
+
+// textWidth approximates the width in pixels of s in 11px Verdana.
+func textWidth(s string) int {
+	var width float64
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune("il.:|!'", r):
+			width += 3.5
+		case strings.ContainsRune("fjrt() ", r):
+			width += 4.5
+		case r == '%' || r == 'm' || r == 'w' || r == 'M' || r == 'W':
+			width += 11
+		case r >= 'A' && r <= 'Z':
+			width += 7.5
+		default:
+			width += 7
+		}
+	}
+	return int(width + 0.5)
+}
+
+func escape(s string) (string, error) {
+	var b strings.Builder
+	if err := xml.EscapeText(&b, []byte(s)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+var svgTemplate = template.Must(template.New("badge").Funcs(template.FuncMap{"escape": escape}).Parse(
+	`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{escape .Label}}: {{escape .Value}}">
+<title>{{escape .Label}}: {{escape .Value}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="{{.LabelWidth}}" height="20" fill="#555"/><rect x="{{.LabelWidth}}" width="{{.ValueWidth}}" height="20" fill="{{escape .Color}}"/><rect width="{{.Width}}" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" text-rendering="geometricPrecision" font-size="110">
+<text aria-hidden="true" x="{{.LabelX}}" y="150" fill="#010101" fill-opacity=".3" transform="scale(.1)" textLength="{{.LabelLen}}">{{escape .Label}}</text>
+<text x="{{.LabelX}}" y="140" transform="scale(.1)" fill="#fff" textLength="{{.LabelLen}}">{{escape .Label}}</text>
+<text aria-hidden="true" x="{{.ValueX}}" y="150" fill="#010101" fill-opacity=".3" transform="scale(.1)" textLength="{{.ValueLen}}">{{escape .Value}}</text>
+<text x="{{.ValueX}}" y="140" transform="scale(.1)" fill="#fff" textLength="{{.ValueLen}}">{{escape .Value}}</text>
+</g>
+</svg>
+`))
@@ -0,0 +1,27 @@
+package badge
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColor(t *testing.T) {
+	assert.Equal(t, "#4c1", Color(95, nil))
+	assert.Equal(t, "#97ca00", Color(80, nil))
+	assert.Equal(t, "#e05d44", Color(10, nil))
+
+	scale := []ColorStop{{Threshold: 50, Color: "green"}, {Threshold: 75, Color: "blue"}}
+	assert.Equal(t, "blue", Color(80, scale))
+	assert.Equal(t, "green", Color(60, scale))
+	assert.Equal(t, "green", Color(10, scale))
+}
+
+func TestWriteSVG(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Coverage("cover <&>", 81.98, nil).WriteSVG(&buf))
+	svg := buf.String()
+	assert.Contains(t, svg, `aria-label="cover &lt;&amp;&gt;: 81.98%"`)
+	assert.Contains(t, svg, `fill="#97ca00"`)
+}
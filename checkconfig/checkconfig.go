@@ -0,0 +1,208 @@
+// Package checkconfig validates the .covercheck configuration file.
+package checkconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/timonwong/alauda-pipeline-cover/coverreport"
+)
+
+const (
+	ModeFiles      = "files"
+	ModePackages   = "packages"
+	ModeComponents = "components"
+)
+
+// Error is a problem found at a position of the config file.
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Errors are all problems found in the config file, in order of position.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindNumber
+	kindMap
+	kindList
+)
+
+// field is the schema of a config value
+type field struct {
+	kind     kind
+	enum     []string
+	fields   map[string]*field // of kindMap
+	required []string          // of kindMap
+	elem     *field            // of kindList
+}
+
+var stringList = &field{kind: kindList, elem: &field{kind: kindString}}
+
+// Schema of the config file
+var schema = &field{
+	kind: kindMap,
+	fields: map[string]*field{
+		"root":     {kind: kindString},
+		"excludes": stringList,
+		"sort_by": {kind: kindString, enum: []string{
+			coverreport.SortByFilename, coverreport.SortByPackage,
+			coverreport.SortByBlock, coverreport.SortByStmt,
+			coverreport.SortByMissingBlocks, coverreport.SortByMissingStmts,
+			coverreport.SortByBlockCoverage, coverreport.SortByStmtCoverage,
+		}},
+		"order": {kind: kindString, enum: []string{coverreport.OrderAsc, coverreport.OrderDesc}},
+		"mode":  {kind: kindString, enum: []string{ModeFiles, ModePackages, ModeComponents}},
+		"badge": {kind: kindMap, fields: map[string]*field{
+			"label": {kind: kindString},
+			"colors": {kind: kindList, elem: &field{
+				kind: kindMap,
+				fields: map[string]*field{
+					"threshold": {kind: kindNumber},
+					"color":     {kind: kindString},
+				},
+				required: []string{"threshold", "color"},
+			}},
+		}},
+		"components": {kind: kindList, elem: &field{
+			kind: kindMap,
+			fields: map[string]*field{
+				"name":      {kind: kindString},
+				"paths":     stringList,
+				"threshold": {kind: kindNumber},
+			},
+			// paths may be inherited from a parent config
+			required: []string{"name"},
+		}},
+		"codeowners": {kind: kindString},
+		"weak_hits":  {kind: kindNumber},
+	},
+}
+
+// Validate checks the YAML config for syntax errors, unknown keys, values of
+// wrong types and values not in the allowed set. Problems are returned as
+// Errors.
+func Validate(data []byte) error {
+	var doc yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			// An empty config
+			return nil
+		}
+		return err
+	}
+
+	var errs Errors
+	if len(doc.Content) > 0 {
+		errs = validate(doc.Content[0], schema, "", errs)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	return errs
+}
+
+func validate(node *yaml.Node, f *field, path string, errs Errors) Errors {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Tag == "!!null" {
+		return errs
+	}
+
+	fail := func(format string, args ...interface{}) Errors {
+		return append(errs, &Error{Line: node.Line, Column: node.Column, Message: path + ": " + fmt.Sprintf(format, args...)})
+	}
+
+	switch f.kind {
+	case kindString:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!str" {
+			return fail("must be a string")
+		}
+		if len(f.enum) > 0 && !contains(f.enum, node.Value) {
+			return fail("must be one of %s, got %q", strings.Join(f.enum, ", "), node.Value)
+		}
+	case kindNumber:
+		if node.Kind != yaml.ScalarNode || (node.Tag != "!!int" && node.Tag != "!!float") {
+			return fail("must be a number")
+		}
+	case kindList:
+		if node.Kind != yaml.SequenceNode {
+			return fail("must be a list")
+		}
+		for i, item := range node.Content {
+			errs = validate(item, f.elem, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case kindMap:
+		if node.Kind != yaml.MappingNode {
+			if path == "" {
+				path = "config"
+			}
+			return fail("must be a mapping")
+		}
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinPath(path, key.Value)
+			child, ok := f.fields[key.Value]
+			if !ok {
+				errs = append(errs, &Error{Line: key.Line, Column: key.Column, Message: fmt.Sprintf("%s: unknown key", keyPath)})
+				continue
+			}
+			seen[key.Value] = true
+			errs = validate(value, child, keyPath, errs)
+		}
+		for _, name := range f.required {
+			if !seen[name] {
+				errs = fail("missing required key %q", name)
+			}
+		}
+	}
+	return errs
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package checkconfig
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timonwong/alauda-pipeline-cover/testdata"
+)
+
+func TestValidateValid(t *testing.T) {
+	assert.NoError(t, Validate(nil))
+	assert.NoError(t, Validate([]byte(`
+root: github.com/foo/bar
+excludes:
+  - "**/mock/**"
+sort_by: stmt-coverage
+order: asc
+mode: components
+weak_hits: 3
+components:
+  - name: storage
+    paths: ["**/storage/**"]
+    threshold: 80
+badge:
+  colors:
+    - threshold: 90.5
+      color: green
+`)))
+}
+
+func TestValidateErrors(t *testing.T) {
+	err := Validate([]byte(`exclude:
+  - foo
+sort_by: coverage
+order: 1
+mode: files
+components:
+  - name: storage
+    paths: "**/storage/**"
+  - threshold: high
+`))
+	assert.Equal(t, Errors{
+		{Line: 1, Column: 1, Message: "exclude: unknown key"},
+		{Line: 3, Column: 10, Message: `sort_by: must be one of filename, package, block, stmt, missing-blocks, missing-stmts, block-coverage, stmt-coverage, got "coverage"`},
+		{Line: 4, Column: 8, Message: "order: must be a string"},
+		{Line: 8, Column: 12, Message: "components[0].paths: must be a list"},
+		{Line: 9, Column: 5, Message: `components[1]: missing required key "name"`},
+		{Line: 9, Column: 16, Message: "components[1].threshold: must be a number"},
+	}, err)
+}
+
+func TestValidateSyntaxError(t *testing.T) {
+	assert.Error(t, Validate([]byte("mode: [files")))
+}
+
+func TestValidateEmptyConfig(t *testing.T) {
+	data, err := os.ReadFile(testdata.Filename("emptyconfig.yml"))
+	assert.NoError(t, err)
+	assert.NoError(t, Validate(data))
+}
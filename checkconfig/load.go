@@ -0,0 +1,166 @@
+package checkconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the names of config files, in order of preference. JSON is
+// read as YAML.
+var FileNames = []string{".covercheck.yml", ".covercheck.yaml", ".covercheck.json", ".covercheck"}
+
+// TOML config files used to be found too, they are rejected rather than
+// silently ignored.
+const tomlFileName = ".covercheck.toml"
+
+// Find returns the config files from the repository root down to dir, the
+// repository root is the closest ancestor containing .git. Only dir is
+// searched if it is not inside a repository, configs of other directories
+// never apply.
+func Find(dir string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{dir}
+	for d := dir; !exists(filepath.Join(d, ".git")); {
+		parent := filepath.Dir(d)
+		if parent == d {
+			// Not inside a repository
+			dirs = []string{dir}
+			break
+		}
+		d = parent
+		dirs = append(dirs, d)
+	}
+
+	var files []string
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if filename := filepath.Join(dirs[i], tomlFileName); exists(filename) {
+			return nil, unsupportedTOML(filename)
+		}
+		for _, name := range FileNames {
+			if filename := filepath.Join(dirs[i], name); exists(filename) {
+				files = append(files, filename)
+				break
+			}
+		}
+	}
+	return files, nil
+}
+
+func unsupportedTOML(filename string) error {
+	return fmt.Errorf("%s: TOML config is not supported, convert it to YAML", filename)
+}
+
+func exists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+// ValidateFile validates the config file, problems are returned one per
+// line prefixed with the file position.
+func ValidateFile(filename string) error {
+	if strings.EqualFold(filepath.Ext(filename), ".toml") {
+		return unsupportedTOML(filename)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	err = Validate(data)
+	var errs Errors
+	if errors.As(err, &errs) {
+		lines := make([]string, 0, len(errs))
+		for _, e := range errs {
+			lines = append(lines, fmt.Sprintf("%s:%v", filename, e))
+		}
+		return errors.New(strings.Join(lines, "\n"))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+// Load validates the config files and merges them in order, settings of
+// later files override earlier ones except that excludes are appended and
+// components are merged by name. A relative codeowners path is resolved
+// against the directory of its config file.
+func Load(filenames []string) (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+	for _, filename := range filenames {
+		if err := ValidateFile(filename); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		var layer map[string]interface{}
+		if err := yaml.Unmarshal(data, &layer); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+
+		if codeowners, ok := layer["codeowners"].(string); ok && !filepath.IsAbs(codeowners) {
+			layer["codeowners"] = filepath.Join(filepath.Dir(filename), codeowners)
+		}
+		merge(settings, layer)
+	}
+	return settings, nil
+}
+
+func merge(settings, layer map[string]interface{}) {
+	for key, value := range layer {
+		switch key {
+		case "excludes":
+			excludes, _ := settings[key].([]interface{})
+			more, _ := value.([]interface{})
+			settings[key] = append(excludes, more...)
+		case "components":
+			components, _ := settings[key].([]interface{})
+			more, _ := value.([]interface{})
+			settings[key] = mergeComponents(components, more)
+		default:
+			current, ok1 := settings[key].(map[string]interface{})
+			override, ok2 := value.(map[string]interface{})
+			if ok1 && ok2 {
+				merge(current, override)
+			} else {
+				settings[key] = value
+			}
+		}
+	}
+}
+
+func mergeComponents(components, more []interface{}) []interface{} {
+	merged := make([]interface{}, 0, len(components)+len(more))
+	merged = append(merged, components...)
+	for _, m := range more {
+		component, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		found := false
+		for _, c := range merged {
+			if existing, ok := c.(map[string]interface{}); ok && existing["name"] == component["name"] {
+				for k, v := range component {
+					existing[k] = v
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, component)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,108 @@
+package checkconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, filename, content string) {
+	t.Helper()
+	assert.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o755))
+	assert.NoError(t, os.WriteFile(filename, []byte(content), 0o600))
+}
+
+func TestFindAndLoad(t *testing.T) {
+	assert := assert.New(t)
+	repo := t.TempDir()
+	assert.NoError(os.Mkdir(filepath.Join(repo, ".git"), 0o755))
+	writeConfig(t, filepath.Join(repo, ".covercheck.yml"), `
+mode: files
+excludes: ["**/mock/**"]
+codeowners: CODEOWNERS
+components:
+  - name: storage
+    paths: ["**/storage/**"]
+    threshold: 80
+badge:
+  label: cover
+`)
+	writeConfig(t, filepath.Join(repo, "module", ".covercheck.yaml"), `
+excludes: ["**/gen/**"]
+components:
+  - name: storage
+    threshold: 90
+  - name: api
+    paths: ["**/api/**"]
+badge:
+  colors: [{threshold: 0, color: red}]
+`)
+	module := filepath.Join(repo, "module", "sub")
+	assert.NoError(os.MkdirAll(module, 0o755))
+
+	files, err := Find(module)
+	assert.NoError(err)
+	assert.Equal([]string{
+		filepath.Join(repo, ".covercheck.yml"),
+		filepath.Join(repo, "module", ".covercheck.yaml"),
+	}, files)
+
+	settings, err := Load(files)
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		"mode":       "files",
+		"excludes":   []interface{}{"**/mock/**", "**/gen/**"},
+		"codeowners": filepath.Join(repo, "CODEOWNERS"),
+		"components": []interface{}{
+			map[string]interface{}{"name": "storage", "paths": []interface{}{"**/storage/**"}, "threshold": 90},
+			map[string]interface{}{"name": "api", "paths": []interface{}{"**/api/**"}},
+		},
+		"badge": map[string]interface{}{
+			"label":  "cover",
+			"colors": []interface{}{map[string]interface{}{"threshold": 0, "color": "red"}},
+		},
+	}, settings)
+}
+
+func TestFindOutsideRepository(t *testing.T) {
+	dir := t.TempDir()
+	files, err := Find(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	writeConfig(t, filepath.Join(dir, ".covercheck.yml"), "mode: files\n")
+	files, err = Find(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, ".covercheck.yml")}, files)
+}
+
+func TestFindJSON(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".covercheck.json"), `{"mode": "files", "excludes": ["**/mock/**"]}`)
+	files, err := Find(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, ".covercheck.json")}, files)
+
+	settings, err := Load(files)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"mode": "files", "excludes": []interface{}{"**/mock/**"}}, settings)
+}
+
+func TestFindTOML(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".covercheck.toml"), "mode = \"files\"\n")
+	_, err := Find(dir)
+	assert.EqualError(t, err, filepath.Join(dir, ".covercheck.toml")+": TOML config is not supported, convert it to YAML")
+
+	_, err = Load([]string{filepath.Join(dir, ".covercheck.toml")})
+	assert.EqualError(t, err, filepath.Join(dir, ".covercheck.toml")+": TOML config is not supported, convert it to YAML")
+}
+
+func TestLoadInvalid(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".covercheck.yml")
+	writeConfig(t, filename, "mode: tree\n")
+	_, err := Load([]string{filename})
+	assert.EqualError(t, err, filename+`:1:7: mode: must be one of files, packages, components, got "tree"`)
+}
@@ -0,0 +1,109 @@
+// Package cienv detects flag values from the environment of CI providers.
+package cienv
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/timonwong/alauda-pipeline-cover/constants"
+)
+
+const (
+	ProviderAuto = "auto"
+	ProviderNone = "none"
+)
+
+// LookupFunc looks up an environment variable, see os.LookupEnv.
+type LookupFunc func(key string) (string, bool)
+
+// Provider describes the environment variables of a CI provider.
+type Provider struct {
+	Name string
+	// marker is set in every job run by the provider
+	marker string
+	// vars maps flag names to environment variables in order of preference,
+	// git refs are only detected from merge requests as branch pipelines
+	// have no baseline to compare against but their own branch
+	vars map[string][]string
+}
+
+// Value is a flag value detected from an environment variable.
+type Value struct {
+	Flag  string
+	Env   string
+	Value string
+}
+
+// Providers are the known CI providers, in order of detection.
+var Providers = []*Provider{
+	{
+		Name:   "gitlab",
+		marker: "GITLAB_CI",
+		vars: map[string][]string{
+			constants.ProjectID: {"CI_PROJECT_ID"},
+			constants.GitRef:    {"CI_MERGE_REQUEST_TARGET_BRANCH_NAME"},
+			constants.GitSHA:    {"CI_COMMIT_SHA"},
+			constants.APIBase:   {"CI_API_V4_URL"},
+		},
+	},
+	{
+		// GitHub Actions knows nothing about the GitLab project, only git refs are detected
+		Name:   "github",
+		marker: "GITHUB_ACTIONS",
+		vars: map[string][]string{
+			constants.GitRef: {"GITHUB_BASE_REF"},
+			constants.GitSHA: {"GITHUB_SHA"},
+		},
+	},
+	{
+		// gitlab* variables are set by the Jenkins GitLab plugin
+		Name:   "jenkins",
+		marker: "JENKINS_URL",
+		vars: map[string][]string{
+			constants.ProjectID: {"gitlabMergeRequestTargetProjectId"},
+			constants.GitRef:    {"gitlabTargetBranch", "CHANGE_TARGET"},
+			constants.GitSHA:    {"GIT_COMMIT"},
+		},
+	},
+}
+
+// Detect returns the provider by name, or the first provider found in the
+// environment if name is auto or empty. It returns nil if no provider is
+// detected or name is none.
+func Detect(name string, lookup LookupFunc) (*Provider, error) {
+	switch name {
+	case "", ProviderAuto:
+		for _, p := range Providers {
+			if v, ok := lookup(p.marker); ok && v != "" {
+				return p, nil
+			}
+		}
+		return nil, nil
+	case ProviderNone:
+		return nil, nil
+	}
+
+	for _, p := range Providers {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown ci provider %q", name)
+}
+
+// Values returns the non-empty flag values found in the environment, sorted by flag name.
+func (p *Provider) Values(lookup LookupFunc) []Value {
+	var values []Value
+	for flag, envs := range p.vars {
+		for _, env := range envs {
+			if v, ok := lookup(env); ok && v != "" {
+				values = append(values, Value{Flag: flag, Env: env, Value: v})
+				break
+			}
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Flag < values[j].Flag
+	})
+	return values
+}
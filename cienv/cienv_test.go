@@ -0,0 +1,77 @@
+package cienv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timonwong/alauda-pipeline-cover/constants"
+)
+
+func lookupMap(env map[string]string) LookupFunc {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestDetectGitLabMergeRequest(t *testing.T) {
+	assert := assert.New(t)
+	lookup := lookupMap(map[string]string{
+		"GITLAB_CI":                           "true",
+		"CI_PROJECT_ID":                       "42",
+		"CI_COMMIT_REF_NAME":                  "feature",
+		"CI_MERGE_REQUEST_TARGET_BRANCH_NAME": "main",
+		"CI_COMMIT_SHA":                       "abc",
+		"CI_API_V4_URL":                       "https://gitlab.example.com/api/v4",
+	})
+	p, err := Detect(ProviderAuto, lookup)
+	assert.NoError(err)
+	assert.Equal("gitlab", p.Name)
+	assert.Equal([]Value{
+		{Flag: constants.APIBase, Env: "CI_API_V4_URL", Value: "https://gitlab.example.com/api/v4"},
+		{Flag: constants.GitRef, Env: "CI_MERGE_REQUEST_TARGET_BRANCH_NAME", Value: "main"},
+		{Flag: constants.GitSHA, Env: "CI_COMMIT_SHA", Value: "abc"},
+		{Flag: constants.ProjectID, Env: "CI_PROJECT_ID", Value: "42"},
+	}, p.Values(lookup))
+}
+
+func TestDetectFallbackEnv(t *testing.T) {
+	lookup := lookupMap(map[string]string{
+		"JENKINS_URL":   "https://jenkins.example.com",
+		"BRANCH_NAME":   "PR-1",
+		"CHANGE_TARGET": "main",
+	})
+	p, err := Detect("", lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, []Value{{Flag: constants.GitRef, Env: "CHANGE_TARGET", Value: "main"}}, p.Values(lookup))
+}
+
+func TestDetectBranchPipeline(t *testing.T) {
+	// Branch pipelines are not compared against their own branch
+	for _, env := range []map[string]string{
+		{"GITLAB_CI": "true", "CI_COMMIT_REF_NAME": "feature"},
+		{"GITHUB_ACTIONS": "true", "GITHUB_REF_NAME": "feature"},
+		{"JENKINS_URL": "https://jenkins.example.com", "BRANCH_NAME": "feature"},
+	} {
+		lookup := lookupMap(env)
+		p, err := Detect(ProviderAuto, lookup)
+		assert.NoError(t, err)
+		assert.Empty(t, p.Values(lookup), p.Name)
+	}
+}
+
+func TestDetectOverride(t *testing.T) {
+	lookup := lookupMap(map[string]string{"GITLAB_CI": "true"})
+
+	p, err := Detect(ProviderNone, lookup)
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	p, err = Detect("github", lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, "github", p.Name)
+
+	_, err = Detect("travis", lookup)
+	assert.Error(t, err)
+}
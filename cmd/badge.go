@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/timonwong/alauda-pipeline-cover/badge"
+	"github.com/timonwong/alauda-pipeline-cover/constants"
+)
+
+// badgeCmd represents the badge command
+var badgeCmd = &cobra.Command{
+	Use:    "badge",
+	Short:  "Generate coverage badge in SVG",
+	PreRun: prerunBindViperFlags,
+	RunE:   runBadge,
+}
+
+func runBadge(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	cfg, err := readCoverCheckConfig()
+	if err != nil {
+		return configError(fmt.Errorf("unable to read config: %w", err))
+	}
+
+	var coverage float64
+	gitRef, err := badgeGitRef(cmd)
+	if err != nil {
+		return err
+	}
+	if gitRef != "" {
+		// Read the coverage stored for the ref instead of generating it
+		if err := requireAPIFlags(); err != nil {
+			return err
+		}
+		baseline, err := readBaseline(cmd.Context(), gitRef)
+		if err != nil {
+			return err
+		}
+		if !baseline.Coverage.Valid {
+			return apiError(errors.New("no coverage found from api"))
+		}
+		coverage = baseline.Coverage.Float64
+		log.Printf("Load coverage %.2f from commit %s", coverage, baseline.SHA)
+	} else {
+		report, err := generateReport(cfg, viper.GetStringSlice(constants.CoverProfile), reportOptionsFromFlags())
+		if err != nil {
+			return err
+		}
+		coverage = report.Total.StmtCoverage
+	}
+
+	var colors []badge.ColorStop
+	if err := cfg.UnmarshalKey("badge.colors", &colors); err != nil {
+		return configError(fmt.Errorf("invalid badge colors: %w", err))
+	}
+
+	b := badge.Coverage(cfg.GetString("badge.label"), coverage, colors)
+	output := viper.GetString(constants.Output)
+	if output == "-" {
+		return b.WriteSVG(os.Stdout)
+	}
+	return writeFile(output, b.WriteSVG)
+}
+
+// badgeGitRef returns the git ref to read the coverage of from api, or empty
+// to generate it from coverprofiles without calling the api.
+func badgeGitRef(cmd *cobra.Command) (string, error) {
+	gitRef := viper.GetString(constants.GitRef)
+	explicit := cmd.Flags().Changed(constants.GitRef)
+	if gitRef == "" || !explicit && cmd.Flags().Changed(constants.CoverProfile) {
+		// A coverprofile given explicitly wins over a git ref detected from CI
+		return "", nil
+	}
+	if token, _, detected := resolveToken(); token != "" && !detected {
+		return gitRef, nil
+	}
+	if !explicit && coverProfilesExist(viper.GetStringSlice(constants.CoverProfile)) {
+		log.Printf("WARNING: flag %s is not set, generate coverage from coverprofiles instead of reading it for %s", constants.APIToken, gitRef)
+		return "", nil
+	}
+	return "", configError(fmt.Errorf("flag %s is required to read coverage of %s from api, or give %s to generate it",
+		constants.APIToken, gitRef, constants.CoverProfile))
+}
+
+func coverProfilesExist(coverprofiles []string) bool {
+	for _, coverprofile := range coverprofiles {
+		if _, err := os.Stat(coverprofile); err != nil && coverprofile != "-" {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFile creates filename and writes to it with write.
+func writeFile(filename string, write func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(badgeCmd)
+
+	badgeCmd.Flags().StringArray(constants.CoverProfile, []string{"coverage.out"}, "Coverage output file or GOCOVERDIR directory, repeat to merge several into one report, - for stdin, gzip or zstd compressed")
+	badgeCmd.Flags().String(constants.GitRef, "", "Read stored coverage of this git ref from api instead of coverprofile, unless only coverprofile is given")
+	badgeCmd.Flags().Int(constants.MaxParents, 0, "Walk up to this many parent commits to find one with coverage")
+	badgeCmd.Flags().StringP(constants.Output, "o", "coverage.svg", "Badge output file, - for stdout")
+}
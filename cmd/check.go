@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 
@@ -9,6 +12,7 @@ import (
 	"github.com/spf13/viper"
 	"gopkg.in/guregu/null.v4"
 
+	"github.com/timonwong/alauda-pipeline-cover/checkconfig"
 	"github.com/timonwong/alauda-pipeline-cover/constants"
 	"github.com/timonwong/alauda-pipeline-cover/coverreport"
 	"github.com/timonwong/alauda-pipeline-cover/covertool"
@@ -16,97 +20,366 @@ import (
 
 // checkCmd represents the check command
 var checkCmd = &cobra.Command{
-	Use:    "check",
-	Short:  "check coverage data",
-	PreRun: prerunBindViperFlags,
-	RunE:   runCheck,
+	Use:     "check",
+	Short:   "check coverage data",
+	PreRunE: prerunRequireAPIFlags,
+	RunE:    runCheck,
 }
 
 func runCheck(cmd *cobra.Command, args []string) error {
-	apiToken := viper.GetString(constants.APIToken)
-	gitRef := viper.GetString(constants.GitRef)
+	// Flags are valid once running, so errors are not usage errors
+	cmd.SilenceUsage = true
 
-	var coverage null.Float
-	if apiToken == "" || gitRef == "" {
+	cfg, err := readCoverCheckConfig()
+	if err != nil {
+		return configError(fmt.Errorf("unable to read config: %w", err))
+	}
+	stdoutColor, err := useColor(os.Stdout)
+	if err != nil {
+		return configError(err)
+	}
+	stderrColor, err := useColor(os.Stderr)
+	if err != nil {
+		return configError(err)
+	}
+
+	opts := checkOptions{
+		CoverProfiles:    viper.GetStringSlice(constants.CoverProfile),
+		GitRef:           viper.GetString(constants.GitRef),
+		DefaultThreshold: viper.GetFloat64(constants.DefaultThreshold),
+		Leeway:           viper.GetFloat64(constants.Leeway),
+		FailOpen:         viper.GetBool(constants.FailOpen),
+		Report:           reportOptionsFromFlags(),
+	}
+	switch apiToken, _, detected := resolveToken(); {
+	case apiToken == "" || opts.GitRef == "":
 		log.Printf("WARNING: flag %s or %s is not set, skip reading coverage from api", constants.APIToken, constants.GitRef)
-	} else {
-		tool, err := covertool.New(viper.GetString(constants.APIBase), apiToken, viper.GetString(constants.ProjectID))
-		if err != nil {
-			return fmt.Errorf("failed to initialize covertool: %w", err)
+	case detected:
+		// Job tokens cannot read commit statuses unless asked for explicitly
+		log.Printf("WARNING: flag %s is not set, skip reading coverage from api with CI_JOB_TOKEN", constants.APIToken)
+	default:
+		opts.ReadBaseline = readBaseline
+	}
+	result, err := check(cmd.Context(), cfg, opts)
+	if err != nil {
+		return err
+	}
+	report := result.Report
+
+	// Print coverage table, filters only apply to the printed rows
+	packages := cfg.GetString("mode") == checkconfig.ModePackages
+	format := viper.GetString(constants.Format)
+	printed := report
+	if minStmts := viper.GetInt64(constants.MinStmts); minStmts > 0 {
+		printed = printed.Filter(minStmts)
+	}
+	top := viper.GetInt(constants.Top)
+	if top > 0 {
+		printed = printed.Top(top, cfg.GetString("sort_by"))
+	}
+	// Rows are highlighted against the threshold gating the run
+	err = coverreport.Print(printed, os.Stdout, coverreport.PrintOptions{
+		Format:    format,
+		Packages:  packages,
+		Threshold: result.Threshold,
+		Color:     stdoutColor,
+	})
+	if err != nil {
+		return configError(err)
+	}
+	if top > 0 && (format == coverreport.FormatTable || format == coverreport.FormatMarkdown) {
+		fmt.Fprintln(os.Stdout, coverreport.FormatTotal(report.Total))
+	}
+	if report.Hotspots != nil {
+		// Keep stdout parsable for the other formats
+		hotspotsOut := os.Stdout
+		if format != coverreport.FormatTable {
+			hotspotsOut = os.Stderr
 		}
+		coverreport.PrintHotspots(report.Hotspots, hotspotsOut)
+	}
+	// Force flush
+	os.Stdout.WriteString("\n")
+	os.Stdout.Sync()
 
-		coverage, err = tool.Read(cmd.Context(), viper.GetString(constants.PipelineName), gitRef)
+	if junitFile := viper.GetString(constants.JUnit); junitFile != "" {
+		err = writeFile(junitFile, func(w io.Writer) error {
+			return coverreport.WriteJUnit(report, w, coverreport.JUnitOptions{
+				Packages:      packages,
+				Threshold:     opts.DefaultThreshold,
+				TotalFailed:   !result.TotalPassed,
+				GateThreshold: result.Threshold,
+				Baseline:      result.Baseline,
+				Leeway:        result.Leeway,
+			})
+		})
 		if err != nil {
-			return fmt.Errorf("unable to read coverage from project: %w", err)
+			return fmt.Errorf("unable to write junit report: %w", err)
 		}
+	}
 
-		log.Printf("Successfully load coverage coverage %.2f from project", coverage.ValueOrZero())
+	verdict, color := formatVerdict(result)
+	if stderrColor {
+		verdict = color.Sprint(verdict)
+	}
+	fmt.Fprintln(os.Stderr, verdict)
+	for _, component := range result.FailedComponents {
+		log.Printf("ERROR: Coverage of %s is below %.2f%%!", component, report.Thresholds[component])
 	}
+	if result.Passed() {
+		return nil
+	}
+	if !result.TotalPassed {
+		return coverageError(fmt.Errorf("coverage %.2f%% is below %.2f%% (leeway=%.2f%%)",
+			report.Total.StmtCoverage, result.Threshold, result.Leeway))
+	}
+	return coverageError(fmt.Errorf("%d components are below their thresholds", len(result.FailedComponents)))
+}
 
-	cfg, err := readCoverCheckConfig()
-	if err != nil {
-		log.Fatalf("unable to read config: %v", err)
+// checkOptions are the inputs of check besides the config file
+type checkOptions struct {
+	CoverProfiles    []string
+	GitRef           string
+	DefaultThreshold float64
+	Leeway           float64
+	// FailOpen falls back to the default threshold if the api fails reading the baseline
+	FailOpen bool
+	// ReadBaseline reads the coverage of the git ref, nil skips reading it
+	ReadBaseline func(ctx context.Context, gitRef string) (*covertool.Baseline, error)
+	Report       reportOptions
+}
+
+// checkResult is the outcome of check
+type checkResult struct {
+	Report *coverreport.Report
+	// Baseline is the coverage read for the git ref
+	Baseline null.Float
+	// Threshold of the total coverage, the larger of the default threshold and the baseline
+	Threshold float64
+	Leeway    float64
+	// TotalPassed is whether the total coverage is at least Threshold minus Leeway
+	TotalPassed bool
+	// FailedComponents are the components below their own thresholds
+	FailedComponents []string
+}
+
+// Passed reports whether the total and every component pass their thresholds
+func (r *checkResult) Passed() bool {
+	return r.TotalPassed && len(r.FailedComponents) == 0
+}
+
+// check generates the coverage report and checks it against the thresholds.
+// Errors carry the exit code of their cause, failed thresholds are not errors.
+func check(ctx context.Context, cfg *viper.Viper, opts checkOptions) (*checkResult, error) {
+	result := &checkResult{Leeway: opts.Leeway}
+	if opts.ReadBaseline != nil {
+		baseline, err := opts.ReadBaseline(ctx, opts.GitRef)
+		switch {
+		case err != nil && opts.FailOpen && exitCode(err) == exitAPI:
+			// Only an unavailable api fails open, broken config still fails
+			log.Printf("WARNING: %v, fall back to default threshold", err)
+		case err != nil:
+			return nil, err
+		case baseline.Coverage.Valid:
+			result.Baseline = baseline.Coverage
+			log.Printf("Successfully load coverage %.2f from commit %s (%d commits behind %s)",
+				baseline.Coverage.Float64, baseline.SHA, baseline.Depth, opts.GitRef)
+		default:
+			log.Printf("WARNING: no coverage found within %d commits behind %s", baseline.Depth, opts.GitRef)
+		}
 	}
 
 	// Generate coverage report
-	packages := cfg.GetString("mode") == "packages"
-	report, err := coverreport.GenerateReport(viper.GetString(constants.CoverProfile), &coverreport.Configuration{
+	report, err := generateReport(cfg, opts.CoverProfiles, opts.Report)
+	if err != nil {
+		return nil, err
+	}
+	result.Report = report
+	for _, stale := range report.Stale {
+		log.Printf("WARNING: coverprofile does not match source %s", stale)
+	}
+	if len(report.Stale) > 0 && opts.Report.StaleProfile == staleFail {
+		return nil, profileError(fmt.Errorf("coverprofiles are stale for %d files", len(report.Stale)))
+	}
+	// The coverage of no statements is NaN, which passes or fails nothing
+	if report.Total.Stmts == 0 {
+		return nil, profileError(errors.New("coverprofiles have no statements left after exclusions"))
+	}
+
+	// Choose threshold, files or packages are only checked against the default one
+	result.Threshold = opts.DefaultThreshold
+	log.Printf("Choose larger coverage between %.2f (default) and %.2f", result.Threshold, result.Baseline.ValueOrZero())
+	if result.Baseline.Valid && result.Baseline.Float64 > result.Threshold {
+		result.Threshold = result.Baseline.Float64
+	}
+
+	// Check thresholds
+	result.TotalPassed = report.Total.StmtCoverage >= result.Threshold-result.Leeway
+	for _, row := range report.Files {
+		if componentThreshold, ok := report.Thresholds[row.Name]; ok && row.StmtCoverage < componentThreshold {
+			result.FailedComponents = append(result.FailedComponents, row.Name)
+		}
+	}
+	return result, nil
+}
+
+// formatVerdict describes whether the total coverage passed the threshold and
+// how it changed against the baseline. Passing only thanks to leeway is yellow.
+func formatVerdict(result *checkResult) (string, coverreport.Color) {
+	total, threshold, leeway := result.Report.Total.StmtCoverage, result.Threshold, result.Leeway
+	delta := "no baseline"
+	if result.Baseline.Valid {
+		delta = fmt.Sprintf("%+.2f%% against baseline %.2f%%", total-result.Baseline.Float64, result.Baseline.Float64)
+	}
+	switch {
+	case !result.TotalPassed:
+		return fmt.Sprintf("FAIL: total coverage %.2f%% is below %.2f%% (leeway=%.2f%%, %s)", total, threshold, leeway, delta), coverreport.ColorRed
+	case total < threshold:
+		return fmt.Sprintf("PASS: total coverage %.2f%% is within leeway of %.2f%% (leeway=%.2f%%, %s)", total, threshold, leeway, delta), coverreport.ColorYellow
+	default:
+		return fmt.Sprintf("PASS: total coverage %.2f%% meets %.2f%% (%s)", total, threshold, delta), coverreport.ColorGreen
+	}
+}
+
+const (
+	staleIgnore = "ignore"
+	staleWarn   = "warn"
+	staleFail   = "fail"
+)
+
+// reportOptions are the inputs of generateReport besides the config file
+type reportOptions struct {
+	Tree bool
+	// Depth collapses the tree deeper than it, implies Tree
+	Depth      int
+	Workers    int
+	Hotspots   int
+	SourceDir  string
+	AddMissing bool
+	// StaleProfile is ignore, warn or fail, empty ignores
+	StaleProfile string
+}
+
+// reportOptionsFromFlags reads the report options from flags
+func reportOptionsFromFlags() reportOptions {
+	return reportOptions{
+		Tree:         viper.GetBool(constants.Tree),
+		Depth:        viper.GetInt(constants.Depth),
+		Workers:      viper.GetInt(constants.ParseWorkers),
+		Hotspots:     viper.GetInt(constants.Hotspots),
+		SourceDir:    viper.GetString(constants.SourceDir),
+		AddMissing:   viper.GetBool(constants.AddMissing),
+		StaleProfile: viper.GetString(constants.StaleProfile),
+	}
+}
+
+func generateReport(cfg *viper.Viper, coverprofiles []string, opts reportOptions) (*coverreport.Report, error) {
+	conf := &coverreport.Configuration{
 		Root:       cfg.GetString("root"),
 		Exclusions: cfg.GetStringSlice("excludes"),
 		SortBy:     cfg.GetString("sort_by"),
 		Order:      cfg.GetString("order"),
-	}, packages)
-	if err != nil {
-		return fmt.Errorf("unable to read coverage: %w", err)
+		Tree:       opts.Tree || opts.Depth > 0,
+		Depth:      opts.Depth,
+		Workers:    opts.Workers,
+		WeakHits:   cfg.GetInt("weak_hits"),
+		Hotspots:   opts.Hotspots,
+		SourceDir:  opts.SourceDir,
+		AddMissing: opts.AddMissing,
+	}
+	switch opts.StaleProfile {
+	case "", staleIgnore:
+	case staleWarn, staleFail:
+		conf.VerifySource = true
+	default:
+		return nil, configError(fmt.Errorf("invalid %s %q, must be one of ignore, warn or fail", constants.StaleProfile, opts.StaleProfile))
+	}
+	if cfg.GetString("mode") == checkconfig.ModeComponents {
+		// Components are no directories to roll up
+		if conf.Tree {
+			return nil, configError(fmt.Errorf("flags %s and %s cannot be used with mode components", constants.Tree, constants.Depth))
+		}
+		components, err := readComponents(cfg)
+		if err != nil {
+			return nil, configError(err)
+		}
+		conf.Components = components
 	}
 
-	// Print coverage table
-	coverreport.PrintTable(report, os.Stdout, packages)
-	// Force flush
-	os.Stdout.WriteString("\n")
-	os.Stdout.Sync()
+	report, err := coverreport.GenerateMergedReport(coverprofiles, conf, cfg.GetString("mode") == checkconfig.ModePackages)
+	var sourceDirErr *coverreport.SourceDirError
+	switch {
+	case errors.As(err, &sourceDirErr):
+		return nil, configError(err)
+	case err != nil:
+		return nil, profileError(fmt.Errorf("unable to read coverage: %w", err))
+	}
+	return report, nil
+}
 
-	// Check threshold
-	threshold := viper.GetFloat64(constants.DefaultThreshold)
-	log.Printf("Choose larger coverage between %.2f (default) and %.2f", threshold, coverage.ValueOrZero())
-	if coverage.Valid && coverage.Float64 > threshold {
-		threshold = coverage.Float64
+// readComponents reads components from config, followed by the ones from codeowners file
+func readComponents(cfg *viper.Viper) ([]coverreport.Component, error) {
+	var components []coverreport.Component
+	if err := cfg.UnmarshalKey("components", &components); err != nil {
+		return nil, fmt.Errorf("invalid components: %w", err)
 	}
 
-	leeway := viper.GetFloat64(constants.Leeway)
-	if report.Total.StmtCoverage < threshold-leeway {
-		log.Fatalf("ERROR: Your coverage is below %.2f%% (leeway=%.2f%%)!", threshold, leeway)
+	if codeowners := cfg.GetString("codeowners"); codeowners != "" {
+		f, err := os.Open(codeowners)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read codeowners: %w", err)
+		}
+		defer f.Close()
+		owners, err := coverreport.ParseCodeowners(f, cfg.GetString("root"))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read codeowners: %w", err)
+		}
+		components = append(components, owners...)
 	}
 
-	return nil
+	if len(components) == 0 {
+		return nil, errors.New("mode components requires components or codeowners in config")
+	}
+	for _, component := range components {
+		if len(component.Paths) == 0 {
+			return nil, fmt.Errorf("component %q has no paths", component.Name)
+		}
+	}
+	return components, nil
 }
 
-func readCoverCheckConfig() (*viper.Viper, error) {
-	v := viper.New()
-
-	v.SetDefault("sort_by", coverreport.SortByPackage)
-	v.SetDefault("order", coverreport.OrderDesc)
-	v.SetDefault("mode", "packages")
-
-	v.SetConfigName(".covercheck")
-	v.SetConfigType("yaml")
-	v.AddConfigPath(".")
-	err := v.ReadInConfig()
+func readBaseline(ctx context.Context, gitRef string) (*covertool.Baseline, error) {
+	tool, err := newCoverTool()
 	if err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, err
-		}
+		return nil, configError(err)
 	}
 
-	return v, nil
+	baseline, err := tool.Read(ctx, viper.GetString(constants.PipelineName), gitRef, viper.GetInt(constants.MaxParents))
+	if err != nil {
+		return nil, apiError(fmt.Errorf("unable to read coverage from project: %w", err))
+	}
+	return baseline, nil
 }
 
 func init() {
 	rootCmd.AddCommand(checkCmd)
 
 	checkCmd.Flags().String(constants.GitRef, "", "The git ref name for target branch")
-	checkCmd.Flags().String(constants.CoverProfile, "coverage.out", "Coverage output file (default coverage.out)")
+	checkCmd.Flags().Int(constants.MaxParents, 0, "Walk up to this many parent commits to find one with coverage")
+	checkCmd.Flags().StringArray(constants.CoverProfile, []string{"coverage.out"}, "Coverage output file or GOCOVERDIR directory, repeat to merge several into one report, - for stdin, gzip or zstd compressed")
+	checkCmd.Flags().Int(constants.ParseWorkers, 0, "Parse coverage output files with this many workers (default GOMAXPROCS)")
 	checkCmd.Flags().Float64(constants.DefaultThreshold, 0, "The default coverage threshold")
 	checkCmd.Flags().Float64(constants.Leeway, 0, "Allow coverage to drop by leeway")
+	checkCmd.Flags().String(constants.Format, coverreport.FormatTable, "Output format (table, markdown, csv or tsv)")
+	checkCmd.Flags().Bool(constants.Tree, false, "Roll up coverage into parent directories and print as a tree, not for mode components")
+	checkCmd.Flags().Int(constants.Depth, 0, "Collapse tree deeper than this into parent directories, implies --tree")
+	checkCmd.Flags().Int(constants.Top, 0, "Print only this many worst rows by the sort_by column and a one-line total")
+	checkCmd.Flags().Int64(constants.MinStmts, 0, "Hide rows with fewer statements than this")
+	checkCmd.Flags().Int(constants.Hotspots, 0, "Print this many most and least executed blocks, to stderr unless format is table")
+	checkCmd.Flags().String(constants.StaleProfile, staleIgnore, "Verify coverprofiles against the module sources in source-dir (ignore, warn or fail)")
+	checkCmd.Flags().Bool(constants.AddMissing, false, "Add source files of the module in source-dir missing from coverprofiles as uncovered")
+	checkCmd.Flags().String(constants.SourceDir, ".", "Root directory of the module the coverprofiles were written for")
+	checkCmd.Flags().String(constants.JUnit, "", "Write a JUnit XML report to this file")
+	checkCmd.Flags().Bool(constants.FailOpen, false, "Fall back to the default threshold if coverage cannot be read from api")
 	checkCmd.MarkFlagRequired(constants.CoverProfile) // nolint: errcheck
 }
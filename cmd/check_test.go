@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/timonwong/alauda-pipeline-cover/coverreport"
+	"github.com/timonwong/alauda-pipeline-cover/covertool"
+	"github.com/timonwong/alauda-pipeline-cover/testdata"
+)
+
+func baselineReader(coverage null.Float, err error) func(context.Context, string) (*covertool.Baseline, error) {
+	return func(context.Context, string) (*covertool.Baseline, error) {
+		if err != nil {
+			return nil, err
+		}
+		return &covertool.Baseline{SHA: "abc", Coverage: coverage}, nil
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cfg := newCoverCheckViper()
+	opts := checkOptions{
+		CoverProfiles:    []string{testdata.Filename("sample_coverage.out")},
+		GitRef:           "main",
+		DefaultThreshold: 80,
+	}
+
+	result, err := check(context.Background(), cfg, opts)
+	assert.NoError(t, err)
+	assert.True(t, result.Passed())
+	assert.Equal(t, 80.0, result.Threshold)
+	assert.InDelta(t, 81.98, result.Report.Total.StmtCoverage, 0.01)
+
+	// The larger baseline is the threshold, leeway allows dropping below it
+	opts.ReadBaseline = baselineReader(null.FloatFrom(85), nil)
+	result, err = check(context.Background(), cfg, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, null.FloatFrom(85), result.Baseline)
+	assert.Equal(t, 85.0, result.Threshold)
+	assert.False(t, result.Passed())
+	verdict, color := formatVerdict(result)
+	assert.Equal(t, "FAIL: total coverage 81.98% is below 85.00% (leeway=0.00%, -3.02% against baseline 85.00%)", verdict)
+	assert.Equal(t, coverreport.ColorRed, color)
+
+	opts.Leeway = 5
+	result, err = check(context.Background(), cfg, opts)
+	assert.NoError(t, err)
+	assert.True(t, result.Passed())
+	_, color = formatVerdict(result)
+	assert.Equal(t, coverreport.ColorYellow, color)
+}
+
+func TestCheckErrors(t *testing.T) {
+	cfg := newCoverCheckViper()
+	opts := checkOptions{
+		CoverProfiles: []string{testdata.Filename("sample_coverage.out")},
+		ReadBaseline:  baselineReader(null.Float{}, apiError(errors.New("unauthorized"))),
+	}
+	_, err := check(context.Background(), cfg, opts)
+	assert.Equal(t, exitAPI, exitCode(err))
+
+	opts.FailOpen = true
+	result, err := check(context.Background(), cfg, opts)
+	assert.NoError(t, err)
+	assert.False(t, result.Baseline.Valid)
+
+	// Broken config does not fail open
+	opts.ReadBaseline = baselineReader(null.Float{}, configError(errors.New("no such ca file")))
+	_, err = check(context.Background(), cfg, opts)
+	assert.Equal(t, exitConfig, exitCode(err))
+	opts.ReadBaseline = nil
+
+	opts.CoverProfiles = []string{testdata.Filename("no_such_coverage.out")}
+	_, err = check(context.Background(), cfg, opts)
+	assert.Equal(t, exitProfile, exitCode(err))
+
+	// Nothing left to cover neither passes nor fails
+	empty := filepath.Join(t.TempDir(), "coverage.out")
+	assert.NoError(t, os.WriteFile(empty, []byte("mode: set\n"), 0o600))
+	_, err = check(context.Background(), cfg, checkOptions{CoverProfiles: []string{empty}})
+	assert.EqualError(t, err, "coverprofiles have no statements left after exclusions")
+	assert.Equal(t, exitProfile, exitCode(err))
+	excluded := newCoverCheckViper()
+	excluded.Set("excludes", []string{"**/*.go"})
+	_, err = check(context.Background(), excluded, checkOptions{CoverProfiles: []string{testdata.Filename("sample_coverage.out")}})
+	assert.Equal(t, exitProfile, exitCode(err))
+
+	cfg.Set("mode", "components")
+	_, err = check(context.Background(), cfg, opts)
+	assert.Equal(t, exitConfig, exitCode(err))
+
+	cfg.Set("components", []map[string]interface{}{{"name": "all", "paths": []string{"."}}})
+	opts.Report.Depth = 2
+	_, err = check(context.Background(), cfg, opts)
+	assert.EqualError(t, err, "flags tree and depth cannot be used with mode components")
+	assert.Equal(t, exitConfig, exitCode(err))
+
+	opts.Report = reportOptions{StaleProfile: "sometimes"}
+	_, err = check(context.Background(), newCoverCheckViper(), opts)
+	assert.Equal(t, exitConfig, exitCode(err))
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, exitError, exitCode(errors.New("boom")))
+	assert.Equal(t, exitCoverage, exitCode(fmt.Errorf("wrapped: %w", coverageError(errors.New("low")))))
+	assert.Equal(t, "low", coverageError(errors.New("low")).Error())
+}
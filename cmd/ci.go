@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/timonwong/alauda-pipeline-cover/cienv"
+	"github.com/timonwong/alauda-pipeline-cover/constants"
+)
+
+// ciCmd represents the ci command
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Print flags detected from CI environment",
+	RunE:  runCI,
+}
+
+func runCI(cmd *cobra.Command, args []string) error {
+	provider, err := cienv.Detect(viper.GetString(constants.CIProvider), os.LookupEnv)
+	if err != nil {
+		return configError(err)
+	}
+	if provider == nil {
+		fmt.Println("No CI provider detected")
+		return nil
+	}
+
+	fmt.Printf("CI provider: %s\n", provider.Name)
+	for _, v := range provider.Values(os.LookupEnv) {
+		fmt.Printf("  --%s=%s (from %s)", v.Flag, v.Value, v.Env)
+		if effective := viper.GetString(v.Flag); effective != v.Value {
+			fmt.Printf(", overridden by %s", effective)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(ciCmd)
+}
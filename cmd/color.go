@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/timonwong/alauda-pipeline-cover/constants"
+)
+
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// useColor reports whether output to f is colorized. In auto mode it is if f
+// is a terminal and NO_COLOR is not set.
+func useColor(f *os.File) (bool, error) {
+	switch mode := viper.GetString(constants.Color); mode {
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	case "", colorAuto:
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		return isTerminal(f), nil
+	default:
+		return false, fmt.Errorf("invalid %s %q, must be one of auto, always or never", constants.Color, mode)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
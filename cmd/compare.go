@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/timonwong/alauda-pipeline-cover/constants"
+	"github.com/timonwong/alauda-pipeline-cover/coverreport"
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:    "compare old-coverprofile new-coverprofile",
+	Short:  "Compare coverage of two coverprofiles",
+	PreRun: prerunBindViperFlags,
+	Args:   cobra.ExactArgs(2),
+	RunE:   runCompare,
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	cfg, err := readCoverCheckConfig()
+	if err != nil {
+		return configError(fmt.Errorf("unable to read config: %w", err))
+	}
+
+	// Compare has none of the report flags of check, so none are read
+	opts := reportOptions{}
+	oldReport, err := generateReport(cfg, args[:1], opts)
+	if err != nil {
+		return err
+	}
+	newReport, err := generateReport(cfg, args[1:], opts)
+	if err != nil {
+		return err
+	}
+
+	leeway := viper.GetFloat64(constants.Leeway)
+	comparison := coverreport.Compare(oldReport, newReport)
+	coverreport.PrintComparison(comparison, os.Stdout, leeway)
+	// Force flush
+	os.Stdout.WriteString("\n")
+	os.Stdout.Sync()
+
+	var regressions int
+	for _, diff := range append(comparison.Rows, comparison.Total) {
+		if diff.Regressed(leeway) {
+			regressions++
+		}
+	}
+	if regressions > 0 {
+		return coverageError(fmt.Errorf("coverage of %d rows dropped by more than %.2f%%", regressions, leeway))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+
+	compareCmd.Flags().Float64(constants.Leeway, 0, "Allow coverage of every row to drop by leeway")
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/timonwong/alauda-pipeline-cover/checkconfig"
+	"github.com/timonwong/alauda-pipeline-cover/coverreport"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage .covercheck config",
+	Long: `Manage .covercheck config.
+
+Without --config, config files are layered from the repository root down to the
+working directory, later ones extending earlier ones. Configs are picked by
+where the command runs, not by the files being checked: a per-module config in
+a subdirectory applies only when running from that directory or below it, so
+run checks of a module from its directory. TOML config files are rejected.`,
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:          "validate [config...]",
+	Short:        "Validate .covercheck config",
+	RunE:         runConfigValidate,
+	SilenceUsage: true,
+}
+
+// configShowCmd represents the config show command
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print effective .covercheck config merged from all config files",
+	RunE:  runConfigShow,
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	files := args
+	if len(files) == 0 {
+		var err error
+		files, err = configFiles()
+		if err != nil {
+			return configError(err)
+		}
+		if len(files) == 0 {
+			return configError(errors.New("no config file found"))
+		}
+	}
+
+	var failed bool
+	for _, filename := range files {
+		if err := checkconfig.ValidateFile(filename); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s: OK\n", filename)
+	}
+	if failed {
+		return configError(errors.New("invalid config"))
+	}
+	return nil
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	files, err := configFiles()
+	if err != nil {
+		return configError(err)
+	}
+	v, err := loadCoverCheckConfig(files)
+	if err != nil {
+		return configError(err)
+	}
+
+	for _, filename := range files {
+		fmt.Printf("# %s\n", filename)
+	}
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(v.AllSettings()); err != nil {
+		return configError(err)
+	}
+	return enc.Close()
+}
+
+// configKey is the viper key of the config flag, so its environment variable
+// is COVERCHECK_CONFIG
+const configKey = "covercheck-config"
+
+// configFiles returns the config file given by flag, or the config files
+// found from the repository root down to the working directory
+func configFiles() ([]string, error) {
+	if filename := viper.GetString(configKey); filename != "" {
+		return []string{filename}, nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return checkconfig.Find(wd)
+}
+
+func newCoverCheckViper() *viper.Viper {
+	v := viper.New()
+
+	v.SetDefault("sort_by", coverreport.SortByPackage)
+	v.SetDefault("order", coverreport.OrderDesc)
+	v.SetDefault("mode", checkconfig.ModePackages)
+	v.SetDefault("badge.label", "coverage")
+	return v
+}
+
+func loadCoverCheckConfig(files []string) (*viper.Viper, error) {
+	settings, err := checkconfig.Load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	v := newCoverCheckViper()
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func readCoverCheckConfig() (*viper.Viper, error) {
+	files, err := configFiles()
+	if err != nil {
+		return nil, err
+	}
+	return loadCoverCheckConfig(files)
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+}
@@ -0,0 +1,645 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timonwong/alauda-pipeline-cover/constants"
+	"github.com/timonwong/alauda-pipeline-cover/testdata"
+)
+
+// resetFlags resets the flags of cmd and its subcommands to their defaults, so
+// every run through rootCmd starts from scratch. String arrays get a new value
+// since pflag appends to them once they have been set.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(flag *pflag.Flag) {
+		if flag.Value.Type() == "stringArray" {
+			var values []string
+			if def := strings.Trim(flag.DefValue, "[]"); def != "" {
+				values = strings.Split(def, ",")
+			}
+			fresh := pflag.NewFlagSet(flag.Name, pflag.ContinueOnError)
+			fresh.StringArray(flag.Name, values, flag.Usage)
+			flag.Value = fresh.Lookup(flag.Name).Value
+		} else {
+			flag.Value.Set(flag.DefValue) // nolint: errcheck
+		}
+		flag.Changed = false
+	}
+	cmd.PersistentFlags().VisitAll(reset)
+	cmd.Flags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// keepSilenceUsage returns a func restoring SilenceUsage of cmd and its
+// subcommands, which commands set once their flags are valid
+func keepSilenceUsage(cmd *cobra.Command) func() {
+	silence := make(map[*cobra.Command]bool)
+	var save func(cmd *cobra.Command)
+	save = func(cmd *cobra.Command) {
+		silence[cmd] = cmd.SilenceUsage
+		for _, sub := range cmd.Commands() {
+			save(sub)
+		}
+	}
+	save(cmd)
+	return func() {
+		for cmd, value := range silence {
+			cmd.SilenceUsage = value
+		}
+	}
+}
+
+// captureFile returns a temp file to replace stdout or stderr with, read by
+// the returned func
+func captureFile(t *testing.T) (*os.File, func() string) {
+	f, err := os.CreateTemp(t.TempDir(), "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, func() string {
+		defer f.Close()
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+}
+
+// ciEnv are the environment variables CI providers are detected from, unset
+// unless a test sets them
+var ciEnv = []string{
+	"GITLAB_CI", "CI_JOB_TOKEN", "CI_PROJECT_ID", "CI_API_V4_URL", "CI_COMMIT_SHA",
+	"CI_COMMIT_REF_NAME", "CI_MERGE_REQUEST_TARGET_BRANCH_NAME",
+	"GITHUB_ACTIONS", "GITHUB_BASE_REF", "GITHUB_REF_NAME", "GITHUB_SHA",
+	"JENKINS_URL", "gitlabMergeRequestTargetProjectId", "gitlabTargetBranch", "CHANGE_TARGET", "BRANCH_NAME", "GIT_COMMIT",
+}
+
+// runCLI runs rootCmd with args against the gitlab outside CI and returns its
+// stdout and stderr, the api is never retried unless args ask for it
+func runCLI(t *testing.T, gitlab *testdata.GitLab, args ...string) (stdout, stderr string, err error) {
+	return execute(t, nil, append([]string{
+		"--api-base", gitlab.APIBase(),
+		"--project-id", "42",
+		"--pipeline-name", "cover",
+		"--api-retries", "0",
+	}, args...))
+}
+
+// runInGitLabCI runs rootCmd with args in a GitLab CI job of the gitlab with env, so
+// the api and project are detected from the environment
+func runInGitLabCI(t *testing.T, gitlab *testdata.GitLab, env map[string]string, args ...string) (stdout, stderr string, err error) {
+	ci := map[string]string{
+		"GITLAB_CI":     "true",
+		"CI_API_V4_URL": gitlab.APIBase(),
+		"CI_PROJECT_ID": "42",
+	}
+	for key, value := range env {
+		ci[key] = value
+	}
+	return execute(t, ci, append([]string{
+		"--pipeline-name", "cover",
+		"--api-retries", "0",
+	}, args...))
+}
+
+func execute(t *testing.T, env map[string]string, args []string) (stdout, stderr string, err error) {
+	defer setenv(env, ciEnv...)()
+
+	outFile, readOut := captureFile(t)
+	errFile, readErr := captureFile(t)
+	origStdout, origStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outFile, errFile
+	log.SetOutput(errFile)
+	defer func() {
+		os.Stdout, os.Stderr = origStdout, origStderr
+		log.SetOutput(origStderr)
+	}()
+	var usage bytes.Buffer
+	rootCmd.SetOut(&usage)
+	rootCmd.SetErr(&usage)
+	defer rootCmd.SetOut(nil)
+	defer rootCmd.SetErr(nil)
+
+	resetFlags(rootCmd)
+	defer resetFlags(rootCmd)
+	defer keepSilenceUsage(rootCmd)()
+	// Forget the defaults detected from CI
+	defer func() {
+		for _, name := range []string{constants.ProjectID, constants.GitRef, constants.GitSHA} {
+			viper.SetDefault(name, nil)
+		}
+		viper.SetDefault(constants.APIBase, rootCmd.PersistentFlags().Lookup(constants.APIBase).DefValue)
+	}()
+	rootCmd.SetArgs(args)
+	err = rootCmd.ExecuteContext(context.Background())
+	return readOut(), readErr() + usage.String(), err
+}
+
+func newTestGitLab() *testdata.GitLab {
+	gitlab := testdata.NewGitLab("secret")
+	gitlab.AddCommit("c1", nil)
+	gitlab.AddCommit("c2", []string{"c1"})
+	gitlab.AddCommit("c3", []string{"c2"}, "main")
+	gitlab.AddStatus("c1", "cover", 80)
+	return gitlab
+}
+
+func TestRead(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+
+	stdout, _, err := runCLI(t, gitlab, "read", "--api-token", "secret", "--git-ref", "main")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.00\n", stdout)
+
+	stdout, _, err = runCLI(t, gitlab, "read", "--api-token", "secret", "--git-ref", "main",
+		"--max-parents", "2", "--format", "json")
+	assert.NoError(t, err)
+	var baseline map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &baseline))
+	assert.Equal(t, map[string]interface{}{"sha": "c1", "depth": 2.0, "coverage": 80.0}, baseline)
+
+	_, stderr, err := runCLI(t, gitlab, "read", "--api-token", "wrong", "--git-ref", "main")
+	assert.Contains(t, err.Error(), "401 Unauthorized")
+	assert.Equal(t, exitAPI, exitCode(err))
+	assert.NotContains(t, stderr, "Usage:")
+
+	_, _, err = runCLI(t, gitlab, "read", "--api-token", "secret", "--git-ref", "main", "--format", "xml")
+	assert.Equal(t, exitConfig, exitCode(err))
+}
+
+func TestWrite(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+
+	_, _, err := runCLI(t, gitlab, "write", "81.5", "--api-token", "secret", "--git-ref", "main")
+	assert.NoError(t, err)
+	_, _, err = runCLI(t, gitlab, "write", "79", "--api-token", "secret", "--git-ref", "main", "--git-sha", "c2")
+	assert.NoError(t, err)
+
+	statuses := gitlab.Statuses("c3")
+	if assert.Len(t, statuses, 1) {
+		assert.Equal(t, "main", statuses[0].Ref)
+		assert.Equal(t, "cover", statuses[0].Name)
+		assert.Equal(t, "success", statuses[0].Status)
+		assert.Equal(t, 81.5, *statuses[0].Coverage)
+	}
+	assert.Len(t, gitlab.Statuses("c2"), 1)
+
+	var requests []string
+	for _, r := range gitlab.Requests() {
+		requests = append(requests, r.Method+" "+r.Path)
+	}
+	assert.Equal(t, []string{
+		"GET projects/42/repository/commits/main",
+		"POST projects/42/statuses/c3",
+		"POST projects/42/statuses/c2",
+	}, requests)
+
+	_, stderr, err := runCLI(t, gitlab, "write", "--api-token", "secret", "--git-ref", "main")
+	assert.Error(t, err)
+	assert.Contains(t, stderr, "Usage:")
+
+	_, stderr, err = runCLI(t, gitlab, "write", "80", "--api-token", "wrong", "--git-ref", "main")
+	assert.Equal(t, exitAPI, exitCode(err))
+	assert.NotContains(t, stderr, "Usage:")
+}
+
+func TestCheckCommand(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	check := func(args ...string) (string, string, error) {
+		args = append([]string{
+			"check",
+			"--config", testdata.Filename("emptyconfig.yml"),
+			"--coverprofile", testdata.Filename("sample_coverage.out"),
+			"--git-ref", "main",
+			"--max-parents", "2",
+		}, args...)
+		return runCLI(t, gitlab, args...)
+	}
+
+	// Sample coverage is 81.98%
+	stdout, stderr, err := check("--api-token", "secret")
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "github.com/mcubik/goverreport/report")
+	assert.Contains(t, stderr, "Successfully load coverage 80.00 from commit c1 (2 commits behind main)")
+	assert.Contains(t, stderr, "PASS: total coverage 81.98% meets 80.00% (+1.98% against baseline 80.00%)")
+
+	_, stderr, err = check("--api-token", "secret", "--max-parents", "1", "--default-threshold", "70")
+	assert.NoError(t, err)
+	assert.Contains(t, stderr, "WARNING: no coverage found within 1 commits behind main")
+	assert.Contains(t, stderr, "PASS: total coverage 81.98% meets 70.00% (no baseline)")
+
+	gitlab.AddStatus("c3", "cover", 90)
+	_, stderr, err = check("--api-token", "secret")
+	assert.Equal(t, exitCoverage, exitCode(err))
+	assert.Contains(t, stderr, "FAIL: total coverage 81.98% is below 90.00%")
+	assert.NotContains(t, stderr, "Usage:")
+
+	_, _, err = check("--api-token", "secret", "--leeway", "10")
+	assert.NoError(t, err)
+
+	_, _, err = check("--api-token", "wrong")
+	assert.Equal(t, exitAPI, exitCode(err))
+
+	_, stderr, err = check("--api-token", "wrong", "--fail-open", "--default-threshold", "70")
+	assert.NoError(t, err)
+	assert.Contains(t, stderr, "fall back to default threshold")
+	assert.Contains(t, stderr, "PASS: total coverage 81.98% meets 70.00% (no baseline)")
+
+	_, _, err = check("--api-token", "secret", "--fail-open", "--token-type", "bogus")
+	assert.Equal(t, exitConfig, exitCode(err))
+	_, _, err = check("--api-token", "secret", "--fail-open", "--api-ca-file", testdata.Filename("no_such_ca.pem"))
+	assert.Equal(t, exitConfig, exitCode(err))
+
+	// Reading from api is skipped without a token
+	requests := len(gitlab.Requests())
+	_, stderr, err = check()
+	assert.NoError(t, err)
+	assert.Contains(t, stderr, "skip reading coverage from api")
+	assert.Len(t, gitlab.Requests(), requests)
+
+	_, _, err = check("--config", testdata.Filename("no_such_config.yml"))
+	assert.Equal(t, exitConfig, exitCode(err))
+
+	_, _, err = runCLI(t, gitlab, "check",
+		"--config", testdata.Filename("emptyconfig.yml"),
+		"--coverprofile", testdata.Filename("no_such_coverage.out"))
+	assert.Equal(t, exitProfile, exitCode(err))
+}
+
+func TestCheckColor(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	gitlab.AddStatus("c3", "cover", 90)
+
+	// The baseline above the default threshold colors the total
+	stdout, stderr, err := runCLI(t, gitlab, "check",
+		"--config", testdata.Filename("emptyconfig.yml"),
+		"--coverprofile", testdata.Filename("sample_coverage.out"),
+		"--api-token", "secret", "--git-ref", "main",
+		"--default-threshold", "70", "--color", "always")
+	assert.Equal(t, exitCoverage, exitCode(err))
+	assert.Contains(t, stderr, "FAIL: total coverage 81.98% is below 90.00%")
+	var footer string
+	for _, line := range strings.Split(stdout, "\n") {
+		if strings.Contains(line, "Total") {
+			footer = line
+		}
+	}
+	assert.Contains(t, footer, "\x1b[31m")
+	assert.NotContains(t, footer, "\x1b[32m")
+}
+
+func TestCheckRetry(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	gitlab.AddStatus("c3", "cover", 75)
+
+	gitlab.FailNext(1)
+	_, stderr, err := runCLI(t, gitlab, "check",
+		"--config", testdata.Filename("emptyconfig.yml"),
+		"--coverprofile", testdata.Filename("sample_coverage.out"),
+		"--api-token", "secret", "--git-ref", "main",
+		"--api-retries", "1", "--api-retry-wait-min", "1ms", "--api-retry-wait-max", "1ms")
+	assert.NoError(t, err)
+	assert.Contains(t, stderr, "+6.98% against baseline 75.00%")
+
+	var paths []string
+	for _, r := range gitlab.Requests() {
+		paths = append(paths, r.Path)
+	}
+	assert.Equal(t, []string{
+		"projects/42/repository/commits/main",
+		"projects/42/repository/commits/main",
+		"projects/42/repository/commits/c3/statuses",
+	}, paths)
+}
+
+// setenv sets the environment variables of env and unsets the other ones in
+// unset until the returned func is called
+func setenv(env map[string]string, unset ...string) func() {
+	orig := make(map[string]*string)
+	save := func(key string) {
+		if _, ok := orig[key]; ok {
+			return
+		}
+		orig[key] = nil
+		if v, ok := os.LookupEnv(key); ok {
+			orig[key] = &v
+		}
+	}
+	for _, key := range unset {
+		save(key)
+		os.Unsetenv(key)
+	}
+	for key, value := range env {
+		save(key)
+		os.Setenv(key, value)
+	}
+	return func() {
+		for key, value := range orig {
+			if value == nil {
+				os.Unsetenv(key)
+			} else {
+				os.Setenv(key, *value)
+			}
+		}
+	}
+}
+
+func TestConfigEnv(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+
+	// A generic CONFIG variable is not the config file
+	restore := setenv(map[string]string{"CONFIG": testdata.Filename("no_such_config.yml")})
+	stdout, _, err := runCLI(t, gitlab, "config", "show")
+	restore()
+	assert.NoError(t, err)
+	assert.NotContains(t, stdout, "no_such_config.yml")
+
+	restore = setenv(map[string]string{"COVERCHECK_CONFIG": testdata.Filename("emptyconfig.yml")})
+	stdout, _, err = runCLI(t, gitlab, "config", "show")
+	restore()
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "# "+testdata.Filename("emptyconfig.yml"))
+}
+
+func TestConfigValidate(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	invalid := filepath.Join(t.TempDir(), ".covercheck.yml")
+	assert.NoError(t, os.WriteFile(invalid, []byte("mode: everything\n"), 0o600))
+
+	stdout, _, err := runCLI(t, gitlab, "config", "validate", testdata.Filename("emptyconfig.yml"))
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "emptyconfig.yml: OK")
+
+	_, stderr, err := runCLI(t, gitlab, "config", "validate", invalid)
+	assert.EqualError(t, err, "invalid config")
+	assert.Equal(t, exitConfig, exitCode(err))
+	assert.Contains(t, stderr, invalid)
+
+	_, _, err = runCLI(t, gitlab, "config", "show", "--config", testdata.Filename("no_such_config.yml"))
+	assert.Equal(t, exitConfig, exitCode(err))
+
+	toml := filepath.Join(t.TempDir(), ".covercheck.toml")
+	assert.NoError(t, os.WriteFile(toml, []byte("mode = \"files\"\n"), 0o600))
+	_, _, err = runCLI(t, gitlab, "check", "--config", toml, "--coverprofile", testdata.Filename("sample_coverage.out"))
+	assert.Contains(t, err.Error(), "TOML config is not supported")
+	assert.Equal(t, exitConfig, exitCode(err))
+}
+
+func TestCheckCoverProfileWithComma(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	data, err := os.ReadFile(testdata.Filename("sample_coverage.out"))
+	assert.NoError(t, err)
+	filename := filepath.Join(t.TempDir(), "unit,integration.out")
+	assert.NoError(t, os.WriteFile(filename, data, 0o600))
+
+	stdout, _, err := runCLI(t, gitlab, "check",
+		"--config", testdata.Filename("emptyconfig.yml"),
+		"--coverprofile", filename,
+		"--coverprofile", testdata.Filename("sample_coverage.out"),
+		"--format", "csv")
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "github.com/mcubik/goverreport/report")
+}
+
+func TestCheckHotspots(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	check := func(format string) (string, string) {
+		stdout, stderr, err := runCLI(t, gitlab, "check",
+			"--config", testdata.Filename("emptyconfig.yml"),
+			"--coverprofile", testdata.Filename("sample_coverage.out"),
+			"--hotspots", "2", "--format", format)
+		assert.NoError(t, err)
+		return stdout, stderr
+	}
+
+	stdout, _ := check("table")
+	assert.Contains(t, stdout, "Most executed")
+
+	// Other formats stay parsable
+	stdout, stderr := check("csv")
+	assert.NotContains(t, stdout, "Most executed")
+	assert.Contains(t, stderr, "Most executed")
+	records, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 4)
+}
+
+func TestCheckSourceDir(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/mcubik/goverreport\n"), 0o600))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "untested"), 0o700))
+	source := "package untested\n\nfunc A() int {\n\treturn 1\n}\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "untested", "a.go"), []byte(source), 0o600))
+
+	stdout, _, err := runCLI(t, gitlab, "check",
+		"--config", testdata.Filename("emptyconfig.yml"),
+		"--coverprofile", testdata.Filename("sample_coverage.out"),
+		"--add-missing", "--source-dir", dir, "--format", "csv")
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "github.com/mcubik/goverreport/untested,1,1,1,1,0,0\n")
+
+	// A source dir without go.mod is a config mistake, not a broken coverprofile
+	for _, flag := range []string{"--add-missing", "--stale-profile=warn"} {
+		_, _, err = runCLI(t, gitlab, "check",
+			"--config", testdata.Filename("emptyconfig.yml"),
+			"--coverprofile", testdata.Filename("sample_coverage.out"),
+			flag, "--source-dir", t.TempDir())
+		assert.Contains(t, err.Error(), "invalid source dir")
+		assert.Equal(t, exitConfig, exitCode(err), flag)
+	}
+}
+
+func TestFlagErrors(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	for _, args := range [][]string{
+		{"check", "--bogus"},
+		{"check", "--default-threshold", "abc"},
+		{"check", "--ci-provider", "bogus"},
+		{"write", "notafloat", "--api-token", "secret", "--git-ref", "main"},
+	} {
+		_, _, err := runCLI(t, gitlab, args...)
+		assert.Equal(t, exitConfig, exitCode(err), args)
+	}
+	assert.Empty(t, gitlab.Requests())
+}
+
+func TestBadgeOffline(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	output := filepath.Join(t.TempDir(), "badge.svg")
+
+	// Coverprofiles need neither the api nor its flags
+	_, _, err := execute(t, nil, []string{"badge",
+		"--config", testdata.Filename("emptyconfig.yml"),
+		"--coverprofile", testdata.Filename("sample_coverage.out"), "-o", output})
+	assert.NoError(t, err)
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "coverage: 81.98%")
+
+	// Reading the git ref needs a token
+	_, _, err = runCLI(t, gitlab, "badge", "--config", testdata.Filename("emptyconfig.yml"), "--git-ref", "main")
+	assert.EqualError(t, err, "flag api-token is required to read coverage of main from api, or give coverprofile to generate it")
+	assert.Equal(t, exitConfig, exitCode(err))
+	mergeRequest := map[string]string{"CI_MERGE_REQUEST_TARGET_BRANCH_NAME": "main"}
+	_, _, err = runInGitLabCI(t, gitlab, mergeRequest, "badge", "--config", testdata.Filename("emptyconfig.yml"))
+	assert.EqualError(t, err, "flag api-token is required to read coverage of main from api, or give coverprofile to generate it")
+	assert.Empty(t, gitlab.Requests())
+
+	_, _, err = execute(t, nil, []string{"badge", "--config", testdata.Filename("emptyconfig.yml"),
+		"--api-base", gitlab.APIBase(), "--api-token", "secret", "--git-ref", "main"})
+	assert.EqualError(t, err, `required flag(s) "project-id" not set`)
+}
+
+func TestCompareIgnoresReportEnv(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	compare := func() string {
+		stdout, _, err := runCLI(t, gitlab, "compare", "--config", testdata.Filename("emptyconfig.yml"),
+			testdata.Filename("sample_coverage.out"), testdata.Filename("sample_coverage.out"))
+		assert.NoError(t, err)
+		return stdout
+	}
+
+	want := compare()
+	defer setenv(map[string]string{"TREE": "true", "DEPTH": "1", "STALE_PROFILE": "fail", "SOURCE_DIR": t.TempDir()})()
+	assert.Equal(t, want, compare())
+}
+
+func TestRequiredFlags(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+
+	// Commands not calling the api run without its flags, and do not stop
+	// other commands from requiring them
+	for _, args := range [][]string{
+		{"ci"},
+		{"compare", testdata.Filename("sample_coverage.out"), testdata.Filename("sample_coverage.out")},
+		{"config", "validate", testdata.Filename("emptyconfig.yml")},
+	} {
+		_, _, err := execute(t, nil, append(args, "--config", testdata.Filename("emptyconfig.yml")))
+		assert.NoError(t, err, args)
+		_, _, err = execute(t, nil, []string{"read", "--api-base", gitlab.APIBase(), "--git-ref", "main"})
+		assert.EqualError(t, err, `required flag(s) "project-id" not set`, args)
+		assert.Equal(t, exitConfig, exitCode(err))
+	}
+	assert.Empty(t, gitlab.Requests())
+}
+
+func TestGitLabCI(t *testing.T) {
+	gitlab := newTestGitLab()
+	defer gitlab.Close()
+	branch := map[string]string{
+		"CI_JOB_TOKEN":       "job-token",
+		"CI_COMMIT_REF_NAME": "feature",
+		"CI_COMMIT_SHA":      "c3",
+	}
+	mergeRequest := map[string]string{"CI_MERGE_REQUEST_TARGET_BRANCH_NAME": "main"}
+	for key, value := range branch {
+		mergeRequest[key] = value
+	}
+	profile := []string{
+		"--config", testdata.Filename("emptyconfig.yml"),
+		"--coverprofile", testdata.Filename("sample_coverage.out"),
+	}
+
+	// Branch pipelines have no baseline, the job token is not used for it
+	_, stderr, err := runInGitLabCI(t, gitlab, branch, append([]string{"check"}, profile...)...)
+	assert.NoError(t, err)
+	assert.Contains(t, stderr, "skip reading coverage from api")
+	assert.Contains(t, stderr, "(no baseline)")
+	_, _, err = runInGitLabCI(t, gitlab, branch, append([]string{"check", "--api-token", "secret"}, profile...)...)
+	assert.NoError(t, err)
+	assert.Empty(t, gitlab.Requests())
+
+	// Merge requests compare against their target branch
+	_, stderr, err = runInGitLabCI(t, gitlab, mergeRequest,
+		append([]string{"check", "--api-token", "secret", "--max-parents", "2"}, profile...)...)
+	assert.NoError(t, err)
+	assert.Contains(t, stderr, "+1.98% against baseline 80.00%")
+	requests := gitlab.Requests()
+	if assert.NotEmpty(t, requests) {
+		assert.Equal(t, "projects/42/repository/commits/main", requests[0].Path)
+	}
+
+	// A coverprofile given to badge wins over the detected git ref
+	requests = gitlab.Requests()
+	stdout, _, err := runInGitLabCI(t, gitlab, mergeRequest, append([]string{"badge", "--output", "-"}, profile...)...)
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "coverage: 81.98%")
+	assert.Equal(t, requests, gitlab.Requests())
+	stdout, _, err = runInGitLabCI(t, gitlab, mergeRequest, "badge", "--output", "-",
+		"--config", testdata.Filename("emptyconfig.yml"), "--api-token", "secret", "--max-parents", "2")
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "coverage: 80.00%")
+
+	// The detected job token is not used to read the baseline
+	requests = gitlab.Requests()
+	_, stderr, err = runInGitLabCI(t, gitlab, mergeRequest, append([]string{"check"}, profile...)...)
+	assert.NoError(t, err)
+	assert.Contains(t, stderr, "skip reading coverage from api with CI_JOB_TOKEN")
+	assert.Equal(t, requests, gitlab.Requests())
+
+	// Other commands use it with a warning
+	_, stderr, err = runInGitLabCI(t, gitlab, branch, "read", "--git-ref", "main")
+	assert.Equal(t, exitAPI, exitCode(err))
+	assert.Contains(t, err.Error(), "job tokens are not allowed to access commit statuses")
+	assert.Contains(t, stderr, "use CI_JOB_TOKEN which cannot access commit statuses")
+	_, _, err = runInGitLabCI(t, gitlab, branch, "read", "--git-ref", "main", "--token-type", "job")
+	assert.Equal(t, exitAPI, exitCode(err))
+	assert.Contains(t, err.Error(), "job tokens are not allowed to access commit statuses")
+
+	// The commit of the job is written without looking it up
+	requests = gitlab.Requests()
+	_, _, err = runInGitLabCI(t, gitlab, branch, "write", "81.5", "--api-token", "secret", "--git-ref", "feature")
+	assert.NoError(t, err)
+	written := gitlab.Requests()[len(requests):]
+	if assert.Len(t, written, 1) {
+		assert.Equal(t, "POST projects/42/statuses/c3", written[0].Method+" "+written[0].Path)
+	}
+	statuses := gitlab.Statuses("c3")
+	assert.Equal(t, "feature", statuses[len(statuses)-1].Ref)
+
+	// An explicit git ref wins over the target branch of merge requests
+	gitlab.AddCommit("c4", []string{"c3"}, "feature")
+	gitlab.AddStatus("c4", "cover", 85)
+	stdout, _, err = runInGitLabCI(t, gitlab, mergeRequest, "read", "--api-token", "secret", "--git-ref", "feature")
+	assert.NoError(t, err)
+	assert.Equal(t, "85.00\n", stdout)
+	_, _, err = runInGitLabCI(t, gitlab, mergeRequest, "write", "86", "--api-token", "secret", "--git-ref", "feature")
+	assert.NoError(t, err)
+	statuses = gitlab.Statuses("c3")
+	assert.Equal(t, "feature", statuses[len(statuses)-1].Ref)
+}
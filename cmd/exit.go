@@ -0,0 +1,51 @@
+package cmd
+
+import "errors"
+
+// Exit codes telling why a command failed
+const (
+	exitError    = 1 // Any other error
+	exitCoverage = 2 // Coverage is below its threshold or regressed
+	exitConfig   = 3 // Invalid config file or flags
+	exitAPI      = 4 // GitLab API request failed
+	exitProfile  = 5 // Coverprofiles cannot be read, are stale or have no statements
+)
+
+// codedError is an error exiting with a specific code
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+func coverageError(err error) error {
+	return &codedError{code: exitCoverage, err: err}
+}
+
+func configError(err error) error {
+	return &codedError{code: exitConfig, err: err}
+}
+
+func apiError(err error) error {
+	return &codedError{code: exitAPI, err: err}
+}
+
+func profileError(err error) error {
+	return &codedError{code: exitProfile, err: err}
+}
+
+// exitCode returns the exit code of an error returned by a command
+func exitCode(err error) int {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	return exitError
+}
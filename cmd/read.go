@@ -1,36 +1,49 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/timonwong/alauda-pipeline-cover/constants"
-	"github.com/timonwong/alauda-pipeline-cover/covertool"
 )
 
 // readCmd represents the read command
 var readCmd = &cobra.Command{
-	Use:    "read",
-	Short:  "Read coverage data",
-	PreRun: prerunBindViperFlags,
-	RunE:   runRead,
+	Use:     "read",
+	Short:   "Read coverage data",
+	PreRunE: prerunRequireAPIFlags,
+	RunE:    runRead,
 }
 
 func runRead(cmd *cobra.Command, args []string) error {
-	tool, err := covertool.New(
-		viper.GetString(constants.APIBase), viper.GetString(constants.APIToken), viper.GetString(constants.ProjectID))
+	// Flags are valid once running, so errors are not usage errors
+	cmd.SilenceUsage = true
+
+	tool, err := newCoverTool()
 	if err != nil {
-		return fmt.Errorf("failed to initialize covertool: %w", err)
+		return configError(err)
 	}
 
-	coverage, err := tool.Read(cmd.Context(), viper.GetString(constants.PipelineName), viper.GetString(constants.GitRef))
+	baseline, err := tool.Read(cmd.Context(),
+		viper.GetString(constants.PipelineName), viper.GetString(constants.GitRef), viper.GetInt(constants.MaxParents))
 	if err != nil {
-		return err
+		return apiError(err)
 	}
 
-	fmt.Printf("%.2f\n", coverage.ValueOrZero())
+	switch format := viper.GetString(constants.Format); format {
+	case "text":
+		fmt.Printf("%.2f\n", baseline.Coverage.ValueOrZero())
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(baseline)
+	default:
+		return configError(fmt.Errorf("format must be either text or json, got %q", format))
+	}
 	return nil
 }
 
@@ -38,5 +51,7 @@ func init() {
 	rootCmd.AddCommand(readCmd)
 
 	readCmd.Flags().String(constants.GitRef, "", "The git ref name for target branch")
+	readCmd.Flags().Int(constants.MaxParents, 0, "Walk up to this many parent commits to find one with coverage")
+	readCmd.Flags().String(constants.Format, "text", "Output format (text or json)")
 	readCmd.MarkFlagRequired(constants.GitRef) // nolint: errcheck
 }
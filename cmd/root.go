@@ -1,20 +1,30 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
+	"github.com/timonwong/alauda-pipeline-cover/cienv"
 	"github.com/timonwong/alauda-pipeline-cover/constants"
+	"github.com/timonwong/alauda-pipeline-cover/covertool"
 )
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use: "alauda-pipeline-cover",
+	Use:               "alauda-pipeline-cover",
+	PersistentPreRunE: prerunPresetFlags,
+	Long: `Check and store Go test coverage with GitLab commit statuses.
+
+Exit codes: 1 for other errors, 2 if coverage is below its threshold or
+regressed, 3 for invalid config or flags, 4 if the GitLab API failed and 5 if
+coverprofiles cannot be read, are stale or have no statements left.`,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -22,7 +32,7 @@ var rootCmd = &cobra.Command{
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -30,16 +40,59 @@ func init() {
 	cobra.OnInitialize(func() {
 		viper.AutomaticEnv()
 		viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-
-		postInitCommands(rootCmd.Commands())
+	})
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return configError(err)
 	})
 
 	addGlobalStringFlag(constants.APIBase, "https://gitlab.com/api/v4", "Base API URL for gitlab")
 	addGlobalStringFlag(constants.APIToken, "", "GitLab API Token")
+	addGlobalStringFlag(constants.TokenType, "", "GitLab API Token type: private, job or oauth, detected if empty: job with CI_JOB_TOKEN in GitLab CI without api-token, otherwise private (oauth is never detected)")
 	addGlobalStringFlag(constants.ProjectID, "", "Gitlab Project ID")
 	addGlobalStringFlag(constants.PipelineName, "alauda-pipeline-cover", "Pipeline name (default: alauda-pipeline-cover)")
-	rootCmd.MarkPersistentFlagRequired(constants.ProjectID)    // nolint: errcheck
-	rootCmd.MarkPersistentFlagRequired(constants.PipelineName) // nolint: errcheck
+	rootCmd.PersistentFlags().String(constants.Config, "", "Config file, also read from COVERCHECK_CONFIG (default: .covercheck.yml, .yaml, .json or .covercheck files from repository root down to working directory, so configs of subdirectories apply only when run inside them; TOML is not supported)")
+	// A generic CONFIG variable is easily set by accident, so the config file is
+	// bound to a key read from a prefixed variable instead
+	if err := viper.BindPFlag(configKey, rootCmd.PersistentFlags().Lookup(constants.Config)); err != nil {
+		log.Fatalf("failed to bind flag: %v", err)
+	}
+	addGlobalStringFlag(constants.CIProvider, cienv.ProviderAuto, "CI provider to detect flags from: auto, none, gitlab, github or jenkins")
+	addGlobalStringFlag(constants.Color, colorAuto, "Colorize output: auto, always or never (auto honors NO_COLOR)")
+	addGlobalDurationFlag(constants.APITimeout, 30*time.Second, "Timeout of every GitLab API request attempt")
+	addGlobalIntFlag(constants.APIRetries, 3, "Retry GitLab API requests failed with 429 or 5xx this many times")
+	addGlobalDurationFlag(constants.APIRetryWaitMin, time.Second, "Minimum wait between GitLab API retries")
+	addGlobalDurationFlag(constants.APIRetryWaitMax, 30*time.Second, "Maximum wait between GitLab API retries")
+	addGlobalStringFlag(constants.APICAFile, "", "PEM encoded CA bundle to verify GitLab server certificate")
+	addGlobalStringFlag(constants.APIClientCert, "", "PEM encoded client certificate for mutual TLS")
+	addGlobalStringFlag(constants.APIClientKey, "", "PEM encoded client key for mutual TLS")
+	addGlobalStringFlag(constants.APIProxy, "", "Proxy URL for GitLab API requests (default from HTTPS_PROXY)")
+	addGlobalBoolFlag(constants.APIInsecureSkipVerify, false, "Skip verification of GitLab server certificate (insecure)")
+}
+
+// prerunPresetFlags presets flags from CI environment before required flags
+// are validated.
+func prerunPresetFlags(cmd *cobra.Command, args []string) error {
+	if err := presetCIFlags(); err != nil {
+		return configError(err)
+	}
+	postInitCommands(cmd.Root().Commands())
+	return nil
+}
+
+// presetCIFlags uses values detected from CI environment as defaults, so
+// flags and their environment variables still take precedence.
+func presetCIFlags() error {
+	provider, err := cienv.Detect(viper.GetString(constants.CIProvider), os.LookupEnv)
+	if err != nil {
+		return fmt.Errorf("failed to detect ci provider: %w", err)
+	}
+	if provider == nil {
+		return nil
+	}
+	for _, v := range provider.Values(os.LookupEnv) {
+		viper.SetDefault(v.Flag, v.Value)
+	}
+	return nil
 }
 
 func postInitCommands(commands []*cobra.Command) {
@@ -57,6 +110,11 @@ func presetRequiredFlags(cmd *cobra.Command) {
 		if !found || requiredAnnotation[0] != "true" {
 			return
 		}
+		// Local flags are not bound to viper yet, so viper misses flags given
+		// explicitly and they must not be overwritten
+		if flag.Changed {
+			return
+		}
 		if viper.IsSet(flag.Name) && viper.GetString(flag.Name) != "" {
 			if err := cmd.Flags().Set(flag.Name, viper.GetString(flag.Name)); err != nil {
 				log.Fatalf("failed to set flag: %v", err)
@@ -67,12 +125,77 @@ func presetRequiredFlags(cmd *cobra.Command) {
 
 func addGlobalStringFlag(name, value, usage string) {
 	rootCmd.PersistentFlags().String(name, value, usage)
+	bindGlobalFlag(name)
+	if value != "" {
+		viper.SetDefault(name, value)
+	}
+}
+
+func addGlobalIntFlag(name string, value int, usage string) {
+	rootCmd.PersistentFlags().Int(name, value, usage)
+	bindGlobalFlag(name)
+}
+
+func addGlobalBoolFlag(name string, value bool, usage string) {
+	rootCmd.PersistentFlags().Bool(name, value, usage)
+	bindGlobalFlag(name)
+}
+
+func addGlobalDurationFlag(name string, value time.Duration, usage string) {
+	rootCmd.PersistentFlags().Duration(name, value, usage)
+	bindGlobalFlag(name)
+}
+
+func bindGlobalFlag(name string) {
 	if err := viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name)); err != nil {
 		log.Fatalf("failed to bind flag: %v", err)
 	}
-	if value != "" {
-		viper.SetDefault(name, value)
+}
+
+// resolveToken returns the api token and its type given by flags, or the job
+// token detected from GitLab CI.
+func resolveToken() (string, covertool.TokenType, bool) {
+	return pickToken(viper.GetString(constants.APIToken), covertool.TokenType(viper.GetString(constants.TokenType)), os.LookupEnv)
+}
+
+// pickToken detects the token type if not given, the job token of GitLab CI
+// is used without a token, otherwise tokens are private ones. OAuth tokens are
+// never detected since no CI variable carries one. Detected is whether the
+// job token was picked without asking for it, which cannot read commit
+// statuses of most GitLab instances.
+func pickToken(token string, tokenType covertool.TokenType, lookupEnv func(string) (string, bool)) (_ string, _ covertool.TokenType, detected bool) {
+	jobToken, ok := lookupEnv("CI_JOB_TOKEN")
+	gitlabCI, _ := lookupEnv("GITLAB_CI")
+	switch {
+	case tokenType == "" && token == "" && ok && gitlabCI == "true":
+		return jobToken, covertool.TokenTypeJob, true
+	case tokenType == "":
+		tokenType = covertool.TokenTypePrivate
+	case tokenType == covertool.TokenTypeJob && token == "" && ok:
+		token = jobToken
 	}
+	return token, tokenType, false
+}
+
+func newCoverTool() (*covertool.Tool, error) {
+	token, tokenType, detected := resolveToken()
+	if detected {
+		log.Printf("WARNING: flag %s is not set, use CI_JOB_TOKEN which cannot access commit statuses of most GitLab instances", constants.APIToken)
+	}
+	tool, err := covertool.New(
+		viper.GetString(constants.APIBase), token, viper.GetString(constants.ProjectID),
+		covertool.WithTokenType(tokenType),
+		covertool.WithTimeout(viper.GetDuration(constants.APITimeout)),
+		covertool.WithRetries(viper.GetInt(constants.APIRetries),
+			viper.GetDuration(constants.APIRetryWaitMin), viper.GetDuration(constants.APIRetryWaitMax)),
+		covertool.WithCACert(viper.GetString(constants.APICAFile)),
+		covertool.WithClientCert(viper.GetString(constants.APIClientCert), viper.GetString(constants.APIClientKey)),
+		covertool.WithProxy(viper.GetString(constants.APIProxy)),
+		covertool.WithInsecureSkipVerify(viper.GetBool(constants.APIInsecureSkipVerify)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize covertool: %w", err)
+	}
+	return tool, nil
 }
 
 func prerunBindViperFlags(cmd *cobra.Command, args []string) {
@@ -80,3 +203,29 @@ func prerunBindViperFlags(cmd *cobra.Command, args []string) {
 		log.Fatalf("failed to bind flags: %v", err)
 	}
 }
+
+// prerunRequireAPIFlags binds flags and requires the flags of the api, for
+// commands always calling it.
+func prerunRequireAPIFlags(cmd *cobra.Command, args []string) error {
+	prerunBindViperFlags(cmd, args)
+	return requireAPIFlags()
+}
+
+// apiFlags are the global flags commands calling the api require
+var apiFlags = []string{constants.ProjectID, constants.PipelineName}
+
+// requireAPIFlags fails if flags of the api are neither given nor detected
+// from CI. The global flags are shared by every command, so they are not
+// marked required for commands which never call the api.
+func requireAPIFlags() error {
+	var missing []string
+	for _, name := range apiFlags {
+		if viper.GetString(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return configError(fmt.Errorf(`required flag(s) "%s" not set`, strings.Join(missing, `", "`)))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timonwong/alauda-pipeline-cover/covertool"
+)
+
+func TestPickToken(t *testing.T) {
+	env := func(vars map[string]string) func(string) (string, bool) {
+		return func(key string) (string, bool) {
+			value, ok := vars[key]
+			return value, ok
+		}
+	}
+	gitlabCI := env(map[string]string{"GITLAB_CI": "true", "CI_JOB_TOKEN": "job-token"})
+	jobToken := env(map[string]string{"CI_JOB_TOKEN": "job-token"})
+	noEnv := env(nil)
+
+	for _, tc := range []struct {
+		name         string
+		token        string
+		tokenType    covertool.TokenType
+		lookupEnv    func(string) (string, bool)
+		wantToken    string
+		wantType     covertool.TokenType
+		wantDetected bool
+	}{
+		// The job token of GitLab CI is detected without a token, but cannot
+		// read commit statuses, so callers are told
+		{name: "ci without token", lookupEnv: gitlabCI, wantToken: "job-token", wantType: covertool.TokenTypeJob, wantDetected: true},
+		{name: "ci with token", token: "secret", lookupEnv: gitlabCI, wantToken: "secret", wantType: covertool.TokenTypePrivate},
+		{name: "job token outside gitlab ci", lookupEnv: jobToken, wantType: covertool.TokenTypePrivate},
+		{name: "private in ci", tokenType: covertool.TokenTypePrivate, lookupEnv: gitlabCI, wantType: covertool.TokenTypePrivate},
+		{name: "job", tokenType: covertool.TokenTypeJob, lookupEnv: jobToken, wantToken: "job-token", wantType: covertool.TokenTypeJob},
+		{name: "job with token", token: "secret", tokenType: covertool.TokenTypeJob, lookupEnv: gitlabCI, wantToken: "secret", wantType: covertool.TokenTypeJob},
+		{name: "job outside ci", tokenType: covertool.TokenTypeJob, lookupEnv: noEnv, wantType: covertool.TokenTypeJob},
+		{name: "oauth", token: "secret", tokenType: covertool.TokenTypeOAuth, lookupEnv: gitlabCI, wantToken: "secret", wantType: covertool.TokenTypeOAuth},
+	} {
+		token, tokenType, detected := pickToken(tc.token, tc.tokenType, tc.lookupEnv)
+		assert.Equal(t, tc.wantToken, token, tc.name)
+		assert.Equal(t, tc.wantType, tokenType, tc.name)
+		assert.Equal(t, tc.wantDetected, detected, tc.name)
+	}
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 
@@ -9,32 +8,36 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/timonwong/alauda-pipeline-cover/constants"
-	"github.com/timonwong/alauda-pipeline-cover/covertool"
 )
 
 // writeCmd represents the write command
 var writeCmd = &cobra.Command{
-	Use:    "write coverage",
-	Short:  "Write coverage data",
-	PreRun: prerunBindViperFlags,
-	Args:   cobra.ExactArgs(1),
-	RunE:   runWrite,
+	Use:     "write coverage",
+	Short:   "Write coverage data",
+	PreRunE: prerunRequireAPIFlags,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runWrite,
 }
 
 func runWrite(cmd *cobra.Command, args []string) error {
-	cover, err := covertool.New(
-		viper.GetString(constants.APIBase), viper.GetString(constants.APIToken), viper.GetString(constants.ProjectID))
+	coverage, err := strconv.ParseFloat(args[0], 64)
 	if err != nil {
-		return fmt.Errorf("failed to initialize covertool: %w", err)
+		return configError(fmt.Errorf("coverage must be a float, got %q", args[0]))
 	}
+	// Flags and args are valid from here, so errors are not usage errors
+	cmd.SilenceUsage = true
 
-	coverage, err := strconv.ParseFloat(args[0], 64)
+	cover, err := newCoverTool()
 	if err != nil {
-		return errors.New("coverage must in float")
+		return configError(err)
 	}
 
-	return cover.Write(cmd.Context(),
+	err = cover.Write(cmd.Context(),
 		viper.GetString(constants.PipelineName), viper.GetString(constants.GitRef), viper.GetString(constants.GitSHA), coverage)
+	if err != nil {
+		return apiError(err)
+	}
+	return nil
 }
 
 func init() {
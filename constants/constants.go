@@ -5,18 +5,50 @@ const (
 
 	APIBase      = "api-base"
 	APIToken     = "api-token"
+	TokenType    = "token-type"
 	ProjectID    = "project-id"
 	PipelineName = "pipeline-name"
+	CIProvider   = "ci-provider"
+	Config       = "config"
+	Color        = "color"
+
+	APITimeout      = "api-timeout"
+	APIRetries      = "api-retries"
+	APIRetryWaitMin = "api-retry-wait-min"
+	APIRetryWaitMax = "api-retry-wait-max"
+
+	APICAFile             = "api-ca-file"
+	APIClientCert         = "api-client-cert"
+	APIClientKey          = "api-client-key"
+	APIProxy              = "api-proxy"
+	APIInsecureSkipVerify = "api-insecure-skip-verify"
 
 	// Common commands
 
-	GitRef = "git-ref"
+	GitRef     = "git-ref"
+	MaxParents = "max-parents"
+	Format     = "format"
 
 	// Compare commands
 
 	CoverProfile     = "coverprofile"
+	ParseWorkers     = "parse-workers"
 	DefaultThreshold = "default-threshold"
 	Leeway           = "leeway"
+	FailOpen         = "fail-open"
+	JUnit            = "junit"
+	Tree             = "tree"
+	Depth            = "depth"
+	Hotspots         = "hotspots"
+	StaleProfile     = "stale-profile"
+	AddMissing       = "add-missing"
+	SourceDir        = "source-dir"
+	Top              = "top"
+	MinStmts         = "min-stmts"
+
+	// Badge commands
+
+	Output = "output"
 
 	// Write commands
 
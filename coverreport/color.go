@@ -0,0 +1,68 @@
+package coverreport
+
+import "github.com/olekukonko/tablewriter"
+
+// NearMargin is how many percentage points above its threshold a coverage is
+// highlighted as near the threshold
+const NearMargin = 5
+
+// Color of terminal output
+type Color int
+
+const (
+	ColorNone Color = iota
+	ColorRed
+	ColorYellow
+	ColorGreen
+)
+
+var ansiColors = map[Color]string{
+	ColorRed:    "\x1b[31m",
+	ColorYellow: "\x1b[33m",
+	ColorGreen:  "\x1b[32m",
+}
+
+// Sprint wraps s in the ANSI escape codes of the color
+func (c Color) Sprint(s string) string {
+	code, ok := ansiColors[c]
+	if !ok {
+		return s
+	}
+	return code + s + "\x1b[0m"
+}
+
+// CoverageColor returns red for coverage below threshold, yellow for coverage
+// within NearMargin above it and green otherwise, a zero threshold has no color
+func CoverageColor(coverage, threshold float64) Color {
+	switch {
+	case threshold <= 0:
+		return ColorNone
+	case coverage < threshold:
+		return ColorRed
+	case coverage < threshold+NearMargin:
+		return ColorYellow
+	default:
+		return ColorGreen
+	}
+}
+
+func (c Color) tableColors() tablewriter.Colors {
+	switch c {
+	case ColorRed:
+		return tablewriter.Colors{tablewriter.FgRedColor}
+	case ColorYellow:
+		return tablewriter.Colors{tablewriter.FgYellowColor}
+	case ColorGreen:
+		return tablewriter.Colors{tablewriter.FgGreenColor}
+	}
+	return tablewriter.Colors{}
+}
+
+// Colors every cell of a row the same
+func rowColors(c Color, cells int) []tablewriter.Colors {
+	colors := make([]tablewriter.Colors, cells)
+	for i := range colors {
+		colors[i] = c.tableColors()
+	}
+	return colors
+}
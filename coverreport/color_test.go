@@ -0,0 +1,39 @@
+package coverreport
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoverageColor(t *testing.T) {
+	assert.Equal(t, ColorNone, CoverageColor(50, 0))
+	assert.Equal(t, ColorRed, CoverageColor(79.9, 80))
+	assert.Equal(t, ColorYellow, CoverageColor(80, 80))
+	assert.Equal(t, ColorYellow, CoverageColor(84.9, 80))
+	assert.Equal(t, ColorGreen, CoverageColor(85, 80))
+
+	assert.Equal(t, "\x1b[31mfail\x1b[0m", ColorRed.Sprint("fail"))
+	assert.Equal(t, "plain", ColorNone.Sprint("plain"))
+}
+
+func TestPrintColorTable(t *testing.T) {
+	report := &Report{
+		Total: Summary{Name: "Total", Blocks: 4, Stmts: 10, MissingBlocks: 1, MissingStmts: 2, BlockCoverage: 75, StmtCoverage: 80},
+		Files: []Summary{
+			{Name: "pkg/a.go", Blocks: 2, Stmts: 5, BlockCoverage: 100, StmtCoverage: 100},
+			{Name: "pkg/b.go", Blocks: 2, Stmts: 5, MissingBlocks: 1, MissingStmts: 2, BlockCoverage: 50, StmtCoverage: 60},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, Print(report, &buf, PrintOptions{Format: FormatTable, Threshold: 78, Color: true}))
+	assert.Contains(t, buf.String(), "\x1b[32mpkg/a.go\x1b[0m")
+	assert.Contains(t, buf.String(), "\x1b[31mpkg/b.go\x1b[0m")
+	assert.Contains(t, buf.String(), "\x1b[33m")
+
+	buf.Reset()
+	assert.NoError(t, Print(report, &buf, PrintOptions{Format: FormatTable, Threshold: 78}))
+	assert.NotContains(t, buf.String(), "\x1b[")
+}
@@ -0,0 +1,122 @@
+package coverreport
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Diff is the coverage change of a row between two reports
+type Diff struct {
+	Name string
+	// Old or New is nil if the row is missing from that report
+	Old, New *Summary
+}
+
+// Comparison of two reports
+type Comparison struct {
+	Item  string
+	Total Diff
+	Rows  []Diff // Rows of the new report in order, followed by removed rows
+}
+
+// Delta is the change of statement coverage, zero if the row is new or removed
+func (d *Diff) Delta() float64 {
+	if d.Old == nil || d.New == nil {
+		return 0
+	}
+	return d.New.StmtCoverage - d.Old.StmtCoverage
+}
+
+// StmtsDelta is the number of statements added, or removed if negative
+func (d *Diff) StmtsDelta() int64 {
+	var stmts int64
+	if d.New != nil {
+		stmts += d.New.Stmts
+	}
+	if d.Old != nil {
+		stmts -= d.Old.Stmts
+	}
+	return stmts
+}
+
+// Regressed reports whether the coverage dropped by more than leeway
+func (d *Diff) Regressed(leeway float64) bool {
+	return d.Delta() < -leeway
+}
+
+// Compare compares the rows of two reports by name
+func Compare(oldReport, newReport *Report) *Comparison {
+	oldRows := make(map[string]*Summary, len(oldReport.Files))
+	for i := range oldReport.Files {
+		oldRows[oldReport.Files[i].Name] = &oldReport.Files[i]
+	}
+
+	c := &Comparison{
+		Item:  newReport.Item,
+		Total: Diff{Name: newReport.Total.Name, Old: &oldReport.Total, New: &newReport.Total},
+	}
+	seen := make(map[string]bool, len(newReport.Files))
+	for i := range newReport.Files {
+		row := &newReport.Files[i]
+		seen[row.Name] = true
+		c.Rows = append(c.Rows, Diff{Name: row.Name, Old: oldRows[row.Name], New: row})
+	}
+	for i := range oldReport.Files {
+		row := &oldReport.Files[i]
+		if !seen[row.Name] {
+			c.Rows = append(c.Rows, Diff{Name: row.Name, Old: row})
+		}
+	}
+	return c
+}
+
+// PrintComparison prints the comparison to the terminal, rows whose
+// coverage dropped by more than leeway are marked as regression
+func PrintComparison(c *Comparison, writer io.Writer, leeway float64) {
+	table := tablewriter.NewWriter(writer)
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_LEFT,
+	})
+	table.SetFooterAlignment(tablewriter.ALIGN_RIGHT)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{c.Item, "Old %", "New %", "Delta %", "Stmts +/-", "Status"})
+	for i := range c.Rows {
+		table.Append(makeDiffRow(&c.Rows[i], leeway))
+	}
+	table.SetFooter(makeDiffRow(&c.Total, leeway))
+	table.Render()
+}
+
+func makeDiffRow(d *Diff, leeway float64) []string {
+	coverage := func(s *Summary) string {
+		if s == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f", s.StmtCoverage)
+	}
+
+	var status string
+	switch {
+	case d.Old == nil:
+		status = "new"
+	case d.New == nil:
+		status = "removed"
+	case d.Regressed(leeway):
+		status = "regression"
+	}
+	return []string{
+		d.Name,
+		coverage(d.Old),
+		coverage(d.New),
+		fmt.Sprintf("%+.2f", d.Delta()),
+		fmt.Sprintf("%+d", d.StmtsDelta()),
+		status,
+	}
+}
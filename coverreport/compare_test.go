@@ -0,0 +1,44 @@
+package coverreport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	assert := assert.New(t)
+	oldReport := &Report{
+		Item:  "File",
+		Total: Summary{Name: "Total", Stmts: 20, StmtCoverage: 80},
+		Files: []Summary{
+			{Name: "a.go", Stmts: 10, StmtCoverage: 90},
+			{Name: "b.go", Stmts: 10, StmtCoverage: 70},
+		},
+	}
+	newReport := &Report{
+		Item:  "File",
+		Total: Summary{Name: "Total", Stmts: 25, StmtCoverage: 76},
+		Files: []Summary{
+			{Name: "c.go", Stmts: 5, StmtCoverage: 0},
+			{Name: "a.go", Stmts: 20, StmtCoverage: 85},
+		},
+	}
+
+	c := Compare(oldReport, newReport)
+	assert.Equal("File", c.Item)
+	assert.InDelta(-4, c.Total.Delta(), 1e-9)
+	assert.EqualValues(5, c.Total.StmtsDelta())
+
+	assert.Len(c.Rows, 3)
+	assert.Equal("c.go", c.Rows[0].Name)
+	assert.Nil(c.Rows[0].Old)
+	assert.False(c.Rows[0].Regressed(0))
+	assert.Equal("a.go", c.Rows[1].Name)
+	assert.True(c.Rows[1].Regressed(1))
+	assert.False(c.Rows[1].Regressed(5))
+	assert.EqualValues(10, c.Rows[1].StmtsDelta())
+	assert.Equal("b.go", c.Rows[2].Name)
+	assert.Nil(c.Rows[2].New)
+	assert.EqualValues(-10, c.Rows[2].StmtsDelta())
+}
@@ -0,0 +1,100 @@
+package coverreport
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Unassigned is the component of files matching no component
+const Unassigned = "(unassigned)"
+
+// Component groups files by path globs, files are assigned to the first
+// component matching them
+type Component struct {
+	Name  string   `mapstructure:"name"`
+	Paths []string `mapstructure:"paths"`
+	// Threshold overrides the default threshold for the component, zero means not set
+	Threshold float64 `mapstructure:"threshold"`
+}
+
+// Returns the name of the first component matching filename
+func componentOf(filename string, components []Component) string {
+	for _, component := range components {
+		if isExcluded(filename, component.Paths) {
+			return component.Name
+		}
+	}
+	return Unassigned
+}
+
+func componentThresholds(components []Component) map[string]float64 {
+	thresholds := make(map[string]float64)
+	for _, component := range components {
+		if component.Threshold > 0 {
+			thresholds[component.Name] = component.Threshold
+		}
+	}
+	return thresholds
+}
+
+// ParseCodeowners reads components from a CODEOWNERS file, every rule is a
+// component named after its owners. Rules are returned in reverse order so
+// that, like in CODEOWNERS, the last matching rule wins. Patterns are
+// relative to root, the prefix of file names in the coverage profile.
+func ParseCodeowners(r io.Reader, root string) ([]Component, error) {
+	var components []Component
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			// Skip comments and GitLab sections
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			// A rule without owners
+			continue
+		}
+		components = append(components, Component{
+			Name:  strings.Join(fields[1:], " "),
+			Paths: codeownersGlobs(fields[0], root),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(components)-1; i < j; i, j = i+1, j-1 {
+		components[i], components[j] = components[j], components[i]
+	}
+	return components, nil
+}
+
+// Converts a gitignore style CODEOWNERS pattern to globs
+func codeownersGlobs(pattern, root string) []string {
+	prefix := root
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	// Patterns with a leading or middle slash are anchored to the root
+	trimmed := strings.TrimSuffix(pattern, "/")
+	if strings.HasPrefix(trimmed, "/") || strings.Contains(trimmed, "/") {
+		trimmed = strings.TrimPrefix(trimmed, "/")
+		if prefix == "" {
+			prefix = "**/"
+		}
+	} else {
+		prefix += "**/"
+	}
+
+	if trimmed == "*" || trimmed == "" {
+		return []string{prefix + "**"}
+	}
+	glob := prefix + trimmed
+	if strings.HasSuffix(pattern, "/") {
+		return []string{glob + "/**"}
+	}
+	// A pattern matches a file or everything in a directory
+	return []string{glob, glob + "/**"}
+}
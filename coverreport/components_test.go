@@ -0,0 +1,60 @@
+package coverreport
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timonwong/alauda-pipeline-cover/testdata"
+)
+
+func TestComponentsReport(t *testing.T) {
+	assert := assert.New(t)
+	report, err := GenerateReport(testdata.Filename("sample_coverage.out"), &Configuration{
+		SortBy: SortByPackage,
+		Order:  OrderAsc,
+		Components: []Component{
+			{Name: "report", Paths: []string{"**/report/*.go"}, Threshold: 95},
+			{Name: "views", Paths: []string{"**/view.go"}},
+		},
+	}, true)
+	assert.NoError(err)
+	assert.Equal("Component", report.Item)
+	assert.Len(report.Files, 2)
+	assert.Equal(Unassigned, report.Files[0].Name)
+	assert.EqualValues(44, report.Files[0].Stmts)
+	// view.go matches report first
+	assert.Equal("report", report.Files[1].Name)
+	assert.EqualValues(67, report.Files[1].Stmts)
+	assert.Equal(95.0, report.Threshold("report", 80))
+	assert.Equal(80.0, report.Threshold(Unassigned, 80))
+}
+
+func TestParseCodeowners(t *testing.T) {
+	assert := assert.New(t)
+	components, err := ParseCodeowners(strings.NewReader(`# comment
+*       @everyone
+[Section]
+report/ @reporters
+/main.go @owner @mcubik
+`), "github.com/mcubik/goverreport")
+	assert.NoError(err)
+	assert.Equal([]Component{
+		{Name: "@owner @mcubik", Paths: []string{"github.com/mcubik/goverreport/main.go", "github.com/mcubik/goverreport/main.go/**"}},
+		{Name: "@reporters", Paths: []string{"github.com/mcubik/goverreport/**/report/**"}},
+		{Name: "@everyone", Paths: []string{"github.com/mcubik/goverreport/**/**"}},
+	}, components)
+
+	report, err := GenerateReport(testdata.Filename("sample_coverage.out"), &Configuration{
+		SortBy:     SortByPackage,
+		Order:      OrderAsc,
+		Components: components,
+	}, false)
+	assert.NoError(err)
+	names := make([]string, 0, len(report.Files))
+	for _, file := range report.Files {
+		names = append(names, file.Name)
+	}
+	assert.Equal([]string{"@owner @mcubik", "@reporters"}, names)
+}
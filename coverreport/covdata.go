@@ -0,0 +1,347 @@
+package coverreport
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Binary coverage data written to GOCOVERDIR by binaries built with
+// go build -cover, see internal/coverage in the Go source tree for the format
+
+const (
+	covMetaPrefix    = "covmeta."
+	covCounterPrefix = "covcounters."
+
+	covMetaFileHeaderSize    = 56
+	covMetaPackageHeaderSize = 44
+	covCounterHeaderSize     = 32
+	covCounterSegHeaderSize  = 16
+	covCounterFooterSize     = 16
+
+	covCounterRaw     = 1
+	covCounterULEB128 = 2
+)
+
+var (
+	covMetaMagic    = [4]byte{0x00, 'c', 'v', 'm'}
+	covCounterMagic = [4]byte{0x00, 'c', 'w', 'm'}
+
+	covModes = map[uint8]string{1: "set", 2: "count", 3: "atomic"}
+)
+
+// A function in a meta-data file with its coverable units
+type covFunc struct {
+	file   string
+	blocks []blockKey
+	stmts  []int32
+}
+
+// Decoded meta-data file, functions are indexed by package then function
+type covMeta struct {
+	mode     string
+	packages [][]covFunc
+}
+
+// Reads a coverage data directory into the set, every unit of the meta-data
+// files is added so unexecuted functions count as missing
+func (p *profileSet) parseCovDataDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	metas := make(map[string]*covMeta)
+	var counters []string
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case entry.IsDir():
+		case strings.HasPrefix(name, covMetaPrefix):
+			meta, err := readCovMeta(filepath.Join(dir, name))
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			metas[strings.TrimPrefix(name, covMetaPrefix)] = meta
+		case strings.HasPrefix(name, covCounterPrefix):
+			counters = append(counters, name)
+		}
+	}
+	if len(metas) == 0 {
+		return errors.New("no coverage meta-data files found")
+	}
+
+	hashes := make([]string, 0, len(metas))
+	for hash := range metas {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		if err := p.addCovMeta(metas[hash]); err != nil {
+			return fmt.Errorf("%s%s: %w", covMetaPrefix, hash, err)
+		}
+	}
+	for _, name := range counters {
+		if err := p.addCovCounters(filepath.Join(dir, name), metas); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Adds the units of a meta-data file with zero counts
+func (p *profileSet) addCovMeta(meta *covMeta) error {
+	if err := p.setMode(meta.mode); err != nil {
+		return err
+	}
+	for _, funcs := range meta.packages {
+		for _, fn := range funcs {
+			blocks, ok := p.files[fn.file]
+			if !ok {
+				blocks = make(map[blockKey]blockValue)
+				p.files[fn.file] = blocks
+			}
+			for i, key := range fn.blocks {
+				if err := p.addBlock(blocks, key, blockValue{numStmt: fn.stmts[i]}); err != nil {
+					return fmt.Errorf("%s: %w", fn.file, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Adds the counters of a counter data file to the units of its meta-data file
+func (p *profileSet) addCovCounters(filename string, metas map[string]*covMeta) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if len(data) < covCounterHeaderSize+covCounterSegHeaderSize+covCounterFooterSize {
+		return errors.New("counter data file too short")
+	}
+	r := &covReader{data: data}
+	if r.magic() != covCounterMagic {
+		return errors.New("invalid counter data file magic")
+	}
+	if version := r.uint32(); version != 1 {
+		return fmt.Errorf("unsupported counter data file version %d", version)
+	}
+	hash := hex.EncodeToString(r.bytes(16))
+	flavor := r.uint8()
+	bigEndian := r.uint8() != 0
+	meta, ok := metas[hash]
+	if !ok {
+		return fmt.Errorf("no meta-data file %s%s", covMetaPrefix, hash)
+	}
+
+	footer := &covReader{data: data, off: len(data) - covCounterFooterSize}
+	if footer.magic() != covCounterMagic {
+		return errors.New("invalid counter data file footer")
+	}
+	footer.uint32()
+	segments := footer.uint32()
+
+	var counter func() uint64
+	switch {
+	case flavor == covCounterULEB128:
+		counter = r.uleb128
+	case flavor == covCounterRaw && bigEndian:
+		counter = func() uint64 { return uint64(binary.BigEndian.Uint32(r.bytes(4))) }
+	case flavor == covCounterRaw:
+		counter = func() uint64 { return uint64(r.uint32()) }
+	default:
+		return fmt.Errorf("unknown counter flavor %d", flavor)
+	}
+
+	r.off = covCounterHeaderSize
+	for seg := uint32(0); seg < segments; seg++ {
+		if seg > 0 {
+			r.skip(covCounterFooterSize)
+		}
+		funcs := r.uint64()
+		// String table and arguments of the run are not needed
+		strTabLen, argsLen := r.uint32(), r.uint32()
+		r.skip(int(strTabLen) + int(argsLen))
+		r.skip((4 - r.off%4) % 4)
+		for i := uint64(0); i < funcs && r.err == nil; i++ {
+			n, pkgIdx, funcIdx := counter(), counter(), counter()
+			if r.err != nil {
+				break
+			}
+			if pkgIdx >= uint64(len(meta.packages)) || funcIdx >= uint64(len(meta.packages[pkgIdx])) {
+				return fmt.Errorf("unknown function %d of package %d", funcIdx, pkgIdx)
+			}
+			fn := meta.packages[pkgIdx][funcIdx]
+			if n > uint64(len(fn.blocks)) {
+				return fmt.Errorf("%s: %d counters for %d units", fn.file, n, len(fn.blocks))
+			}
+			blocks := p.files[fn.file]
+			for j := uint64(0); j < n; j++ {
+				count := counter()
+				if count == 0 {
+					continue
+				}
+				if err := p.addBlock(blocks, fn.blocks[j], blockValue{numStmt: fn.stmts[j], count: int64(count)}); err != nil {
+					return fmt.Errorf("%s: %w", fn.file, err)
+				}
+			}
+		}
+	}
+	return r.err
+}
+
+// Decodes the functions of every package in a meta-data file
+func readCovMeta(filename string) (*covMeta, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < covMetaFileHeaderSize {
+		return nil, errors.New("meta-data file too short")
+	}
+	r := &covReader{data: data}
+	if r.magic() != covMetaMagic {
+		return nil, errors.New("invalid meta-data file magic")
+	}
+	if version := r.uint32(); version != 1 {
+		return nil, fmt.Errorf("unsupported meta-data file version %d", version)
+	}
+	r.uint64() // total length
+	entries := r.uint64()
+	r.skip(16 + 4 + 4) // hash and string table
+	mode, ok := covModes[r.uint8()]
+	if !ok {
+		return nil, errors.New("invalid counter mode")
+	}
+	r.off = covMetaFileHeaderSize
+	if entries > uint64(len(data)) {
+		return nil, fmt.Errorf("invalid number of packages %d", entries)
+	}
+	offsets := make([]uint64, entries)
+	for i := range offsets {
+		offsets[i] = r.uint64()
+	}
+	meta := &covMeta{mode: mode, packages: make([][]covFunc, entries)}
+	for i := range meta.packages {
+		length := r.uint64()
+		if r.err != nil {
+			break
+		}
+		if offsets[i] > uint64(len(data)) || length > uint64(len(data))-offsets[i] {
+			return nil, fmt.Errorf("invalid package %d", i)
+		}
+		funcs, err := readCovPackage(data[offsets[i] : offsets[i]+length])
+		if err != nil {
+			return nil, fmt.Errorf("package %d: %w", i, err)
+		}
+		meta.packages[i] = funcs
+	}
+	return meta, r.err
+}
+
+// Decodes the functions of a package in a meta-data file
+func readCovPackage(data []byte) ([]covFunc, error) {
+	r := &covReader{data: data}
+	r.skip(covMetaPackageHeaderSize - 4)
+	numFuncs := r.uint32()
+	if uint64(numFuncs)*4 > uint64(len(data)) {
+		return nil, fmt.Errorf("invalid number of functions %d", numFuncs)
+	}
+	offsets := make([]uint32, numFuncs)
+	for i := range offsets {
+		offsets[i] = r.uint32()
+	}
+	var strs []string
+	for n := r.uleb128(); n > 0 && r.err == nil; n-- {
+		strs = append(strs, string(r.bytes(int(r.uleb128()))))
+	}
+
+	funcs := make([]covFunc, numFuncs)
+	for i, off := range offsets {
+		r.off = int(off)
+		units := r.uleb128()
+		r.uleb128() // function name
+		fileIdx := r.uleb128()
+		if r.err != nil || fileIdx >= uint64(len(strs)) || units > uint64(len(data)) {
+			return nil, fmt.Errorf("invalid function %d", i)
+		}
+		fn := covFunc{
+			file:   strs[fileIdx],
+			blocks: make([]blockKey, units),
+			stmts:  make([]int32, units),
+		}
+		for j := range fn.blocks {
+			fn.blocks[j] = blockKey{
+				startLine: int32(r.uleb128()),
+				startCol:  int32(r.uleb128()),
+				endLine:   int32(r.uleb128()),
+				endCol:    int32(r.uleb128()),
+			}
+			fn.stmts[j] = int32(r.uleb128())
+		}
+		funcs[i] = fn
+	}
+	return funcs, r.err
+}
+
+// Reads little endian values from a byte slice, the first out of range
+// read sets err and every later read returns zero values
+type covReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (r *covReader) bytes(n int) []byte {
+	if r.err == nil && n >= 0 && n <= len(r.data)-r.off {
+		b := r.data[r.off : r.off+n]
+		r.off += n
+		return b
+	}
+	r.err = errors.New("unexpected end of coverage data")
+	var zero [16]byte
+	if n < 0 {
+		n = 0
+	} else if n > len(zero) {
+		n = len(zero)
+	}
+	return zero[:n]
+}
+
+func (r *covReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *covReader) magic() (m [4]byte) {
+	copy(m[:], r.bytes(4))
+	return m
+}
+
+func (r *covReader) uint8() uint8 {
+	return r.bytes(1)[0]
+}
+
+func (r *covReader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(r.bytes(4))
+}
+
+func (r *covReader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(r.bytes(8))
+}
+
+func (r *covReader) uleb128() uint64 {
+	var value uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b := r.bytes(1)[0]
+		value |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return value
+}
@@ -0,0 +1,63 @@
+package coverreport
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/cover"
+
+	"github.com/timonwong/alauda-pipeline-cover/testdata"
+)
+
+func TestParseCovDataDir(t *testing.T) {
+	// covdata.out is written by go tool covdata textfmt -i covdata
+	expected, err := cover.ParseProfiles(testdata.Filename("covdata.out"))
+	assert.NoError(t, err)
+
+	p := newProfileSet()
+	assert.NoError(t, p.parseFile(testdata.Filename("covdata")))
+	assert.Equal(t, "count", p.mode)
+	var profiles []*cover.Profile
+	p.forEach(func(filename string, blocks []cover.ProfileBlock) {
+		profiles = append(profiles, &cover.Profile{
+			FileName: filename,
+			Mode:     p.mode,
+			Blocks:   append([]cover.ProfileBlock(nil), blocks...),
+		})
+	})
+	assert.Equal(t, expected, profiles)
+}
+
+func TestCovDataMergedReport(t *testing.T) {
+	conf := &Configuration{SortBy: SortByPackage, Order: OrderAsc}
+	report, err := GenerateMergedReport([]string{testdata.Filename("covdata"), testdata.Filename("covdata.out")}, conf, true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 9, report.Total.Stmts)
+	assert.EqualValues(t, 1, report.Total.MissingStmts)
+	assert.Equal(t, "example.com/app", report.Files[0].Name)
+	assert.Equal(t, "example.com/app/greet", report.Files[1].Name)
+}
+
+func TestCovDataErrors(t *testing.T) {
+	p := newProfileSet()
+	assert.Error(t, p.parseFile(t.TempDir()))
+
+	// Counters without their meta-data file
+	dir := t.TempDir()
+	matches, err := filepath.Glob(testdata.Filename("covdata/covcounters.*"))
+	assert.NoError(t, err)
+	data, err := os.ReadFile(matches[0])
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, filepath.Base(matches[0])), data, 0o600))
+	assert.Error(t, newProfileSet().parseFile(dir))
+
+	// Truncated meta-data file
+	matches, err = filepath.Glob(testdata.Filename("covdata/covmeta.*"))
+	assert.NoError(t, err)
+	data, err = os.ReadFile(matches[0])
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, filepath.Base(matches[0])), data[:len(data)/2], 0o600))
+	assert.Error(t, newProfileSet().parseFile(dir))
+}
@@ -0,0 +1,51 @@
+package coverreport
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+var csvHeader = []string{
+	"name", "blocks", "stmts", "missing_blocks", "missing_stmts",
+	"block_coverage", "stmt_coverage"}
+
+// PrintCSV prints every file of the report followed by the total as
+// delimiter separated values, coverage is printed with full precision.
+// Weakly covered counts are appended if the report has WeakHits.
+func PrintCSV(report *Report, writer io.Writer, comma rune) error {
+	w := csv.NewWriter(writer)
+	w.Comma = comma
+	header := csvHeader
+	if report.WeakHits > 0 {
+		header = append(header[:len(header):len(header)], "weak_blocks", "weak_stmts")
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, fileCoverage := range report.Files {
+		if err := w.Write(makeCSVRecord(report, fileCoverage)); err != nil {
+			return err
+		}
+	}
+	if err := w.Write(makeCSVRecord(report, report.Total)); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func makeCSVRecord(report *Report, c Summary) []string {
+	record := []string{
+		c.Name,
+		strconv.FormatInt(c.Blocks, 10),
+		strconv.FormatInt(c.Stmts, 10),
+		strconv.FormatInt(c.MissingBlocks, 10),
+		strconv.FormatInt(c.MissingStmts, 10),
+		strconv.FormatFloat(c.BlockCoverage, 'f', -1, 64),
+		strconv.FormatFloat(c.StmtCoverage, 'f', -1, 64)}
+	if report.WeakHits > 0 {
+		record = append(record, strconv.FormatInt(c.WeakBlocks, 10), strconv.FormatInt(c.WeakStmts, 10))
+	}
+	return record
+}
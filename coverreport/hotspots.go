@@ -0,0 +1,110 @@
+package coverreport
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/olekukonko/tablewriter"
+	"golang.org/x/tools/cover"
+)
+
+// Hotspot is a covered block with its execution count
+type Hotspot struct {
+	File                                 string
+	StartLine, StartCol, EndLine, EndCol int
+	NumStmt                              int
+	Count                                int
+}
+
+// Hotspots are the most and least executed covered blocks, both ordered from
+// the extreme towards the middle
+type Hotspots struct {
+	Most  []Hotspot
+	Least []Hotspot
+}
+
+// Collects the covered blocks of every file
+type hotspotCollector struct {
+	blocks []Hotspot
+}
+
+func (h *hotspotCollector) addAll(file string, blocks []cover.ProfileBlock) {
+	for _, block := range blocks {
+		if block.Count == 0 {
+			continue
+		}
+		h.blocks = append(h.blocks, Hotspot{
+			File:      file,
+			StartLine: block.StartLine,
+			StartCol:  block.StartCol,
+			EndLine:   block.EndLine,
+			EndCol:    block.EndCol,
+			NumStmt:   block.NumStmt,
+			Count:     block.Count,
+		})
+	}
+}
+
+// Returns the n most and n least executed blocks, ties are broken by position
+// so the result is stable
+func (h *hotspotCollector) results(n int) *Hotspots {
+	blocks := h.blocks
+	sort.SliceStable(blocks, func(i, j int) bool {
+		if blocks[i].Count != blocks[j].Count {
+			return blocks[i].Count > blocks[j].Count
+		}
+		return blocks[i].before(blocks[j])
+	})
+	if n > len(blocks) {
+		n = len(blocks)
+	}
+	least := append([]Hotspot(nil), blocks[len(blocks)-n:]...)
+	sort.SliceStable(least, func(i, j int) bool {
+		if least[i].Count != least[j].Count {
+			return least[i].Count < least[j].Count
+		}
+		return least[i].before(least[j])
+	})
+	return &Hotspots{
+		Most:  append([]Hotspot(nil), blocks[:n]...),
+		Least: least,
+	}
+}
+
+func (h Hotspot) before(o Hotspot) bool {
+	if h.File != o.File {
+		return h.File < o.File
+	}
+	return h.StartLine < o.StartLine || h.StartLine == o.StartLine && h.StartCol < o.StartCol
+}
+
+// String formats the block like a coverprofile line
+func (h Hotspot) String() string {
+	return fmt.Sprintf("%s:%d.%d,%d.%d", h.File, h.StartLine, h.StartCol, h.EndLine, h.EndCol)
+}
+
+// PrintHotspots prints the most and least executed blocks to the terminal
+func PrintHotspots(hotspots *Hotspots, writer io.Writer) {
+	printHotspotTable(writer, "Most executed", hotspots.Most)
+	printHotspotTable(writer, "Least executed", hotspots.Least)
+}
+
+func printHotspotTable(writer io.Writer, title string, blocks []Hotspot) {
+	table := tablewriter.NewWriter(writer)
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+	})
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{title, "Stmts", "Count"})
+	for _, block := range blocks {
+		table.Append([]string{
+			block.String(),
+			fmt.Sprintf("%d", block.NumStmt),
+			fmt.Sprintf("%d", block.Count),
+		})
+	}
+	table.Render()
+}
@@ -0,0 +1,76 @@
+package coverreport
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeProfile(t *testing.T, profile string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "coverage.out")
+	assert.NoError(t, os.WriteFile(filename, []byte(profile), 0o600))
+	return filename
+}
+
+const countProfile = `mode: count
+pkg/a.go:1.1,2.2 2 100
+pkg/a.go:3.1,4.2 1 1
+pkg/a.go:5.1,6.2 3 0
+pkg/b.go:1.1,2.2 4 2
+pkg/b.go:3.1,4.2 1 1
+`
+
+func TestWeakHits(t *testing.T) {
+	conf := &Configuration{SortBy: SortByFilename, Order: OrderAsc, WeakHits: 2}
+	report, err := GenerateReport(writeProfile(t, countProfile), conf, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "count", report.Mode)
+	assert.Equal(t, 2, report.WeakHits)
+	assert.EqualValues(t, 2, report.Total.WeakBlocks)
+	assert.EqualValues(t, 2, report.Total.WeakStmts)
+	assert.EqualValues(t, 1, report.Files[0].WeakStmts)
+	assert.EqualValues(t, 1, report.Files[1].WeakStmts)
+
+	var buf bytes.Buffer
+	PrintMarkdown(report, &buf, false, 0)
+	assert.Contains(t, buf.String(), "| File | Blocks | Missing | Stmts | Missing | Block cover % | Stmt cover % | Weak stmts (<2 hits) |\n| :--- | ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	buf.Reset()
+	assert.NoError(t, PrintCSV(report, &buf, ','))
+	assert.Contains(t, buf.String(), "stmt_coverage,weak_blocks,weak_stmts\n")
+
+	// Every covered block is hit once in set mode
+	report, err = GenerateReport(writeProfile(t, "mode: set\npkg/a.go:1.1,2.2 2 1\n"), conf, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report.WeakHits)
+	assert.EqualValues(t, 0, report.Total.WeakStmts)
+}
+
+func TestHotspots(t *testing.T) {
+	conf := &Configuration{SortBy: SortByFilename, Order: OrderAsc, Hotspots: 2}
+	report, err := GenerateReport(writeProfile(t, countProfile), conf, false)
+	assert.NoError(t, err)
+	assert.Equal(t, &Hotspots{
+		Most: []Hotspot{
+			{File: "pkg/a.go", StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 2, Count: 100},
+			{File: "pkg/b.go", StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 4, Count: 2},
+		},
+		Least: []Hotspot{
+			{File: "pkg/a.go", StartLine: 3, StartCol: 1, EndLine: 4, EndCol: 2, NumStmt: 1, Count: 1},
+			{File: "pkg/b.go", StartLine: 3, StartCol: 1, EndLine: 4, EndCol: 2, NumStmt: 1, Count: 1},
+		},
+	}, report.Hotspots)
+
+	var buf bytes.Buffer
+	PrintHotspots(report.Hotspots, &buf)
+	assert.Contains(t, buf.String(), "pkg/a.go:1.1,2.2")
+	assert.Contains(t, buf.String(), "Least executed")
+
+	conf.Hotspots = 0
+	report, err = GenerateReport(writeProfile(t, countProfile), conf, false)
+	assert.NoError(t, err)
+	assert.Nil(t, report.Hotspots)
+}
@@ -0,0 +1,106 @@
+package coverreport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// JUnitOptions controls which rows of a JUnit report fail
+type JUnitOptions struct {
+	Packages bool
+	// Threshold every row without its own threshold is checked against, zero disables the checks
+	Threshold float64
+	// TotalFailed is whether the total is below GateThreshold minus Leeway,
+	// decided by the caller gating the run. GateThreshold is the larger one of
+	// the default threshold and Baseline.
+	TotalFailed   bool
+	GateThreshold float64
+	Baseline      null.Float
+	Leeway        float64
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as a JUnit XML document, every file or
+// package and the total are testcases failing when below their threshold
+func WriteJUnit(report *Report, writer io.Writer, opts JUnitOptions) error {
+	item := report.Item
+	if item == "" {
+		item = itemName(opts.Packages)
+	}
+	classname := "coverage." + strings.ToLower(item) + "s"
+
+	suite := junitTestSuite{Name: "coverage"}
+	for _, fileCoverage := range report.Files {
+		tc := junitTestCase{Classname: classname, Name: fileCoverage.Name}
+		threshold := report.Threshold(fileCoverage.Name, opts.Threshold)
+		if threshold > 0 && fileCoverage.StmtCoverage < threshold {
+			tc.Failure = newJUnitFailure(fmt.Sprintf("coverage %.2f%% is below %.2f%%", fileCoverage.StmtCoverage, threshold), fileCoverage)
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	total := junitTestCase{Classname: "coverage", Name: report.Total.Name}
+	if opts.TotalFailed {
+		baseline := "n/a"
+		if opts.Baseline.Valid {
+			baseline = fmt.Sprintf("%.2f%%", opts.Baseline.Float64)
+		}
+		total.Failure = newJUnitFailure(fmt.Sprintf("coverage %.2f%% is below %.2f%% (baseline=%s, leeway=%.2f%%)",
+			report.Total.StmtCoverage, opts.GateThreshold, baseline, opts.Leeway), report.Total)
+	}
+	suite.Cases = append(suite.Cases, total)
+
+	suite.Tests = len(suite.Cases)
+	for _, tc := range suite.Cases {
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(writer)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
+
+func newJUnitFailure(message string, c Summary) *junitFailure {
+	return &junitFailure{
+		Message: message,
+		Type:    "coverage",
+		Text: fmt.Sprintf("%d of %d statements missing, %d of %d blocks missing",
+			c.MissingStmts, c.Stmts, c.MissingBlocks, c.Blocks),
+	}
+}
@@ -0,0 +1,41 @@
+package coverreport
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	report := &Report{
+		Total: Summary{Name: "Total", Blocks: 4, Stmts: 10, MissingBlocks: 1, MissingStmts: 2, BlockCoverage: 75, StmtCoverage: 80},
+		Files: []Summary{
+			{Name: "pkg/a.go", Blocks: 2, Stmts: 5, BlockCoverage: 100, StmtCoverage: 100},
+			{Name: "pkg/c.go", Blocks: 2, Stmts: 5, MissingBlocks: 1, MissingStmts: 2, BlockCoverage: 50, StmtCoverage: 60},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteJUnit(report, &buf, JUnitOptions{
+		Threshold:     70,
+		TotalFailed:   true,
+		GateThreshold: 85,
+		Baseline:      null.FloatFrom(85),
+		Leeway:        1,
+	}))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="coverage" tests="3" failures="2">
+    <testcase classname="coverage.files" name="pkg/a.go"></testcase>
+    <testcase classname="coverage.files" name="pkg/c.go">
+      <failure message="coverage 60.00% is below 70.00%" type="coverage">2 of 5 statements missing, 1 of 2 blocks missing</failure>
+    </testcase>
+    <testcase classname="coverage" name="Total">
+      <failure message="coverage 80.00% is below 85.00% (baseline=85.00%, leeway=1.00%)" type="coverage">2 of 10 statements missing, 1 of 4 blocks missing</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
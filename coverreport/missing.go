@@ -0,0 +1,206 @@
+package coverreport
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Adds every non-test Go file of the main module that is missing from the set
+// with all its blocks uncovered. Directories ignored by the go command,
+// testdata, vendor, nested modules and files excluded by build constraints are
+// skipped.
+func (p *profileSet) addMissingFiles(sources *sourceResolver, exclusions []string) error {
+	if p.mode == "" {
+		p.mode = "set"
+	}
+	ctxt := build.Default
+	return filepath.WalkDir(sources.dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if filename == sources.dir {
+				return nil
+			}
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(filename, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+		dir := filepath.Dir(filename)
+		if ok, err := ctxt.MatchFile(dir, name); err != nil || !ok {
+			return err
+		}
+
+		rel, err := filepath.Rel(sources.dir, filename)
+		if err != nil {
+			return err
+		}
+		importName := path.Join(sources.module, filepath.ToSlash(rel))
+		if _, ok := p.files[importName]; ok || isExcluded(importName, exclusions) {
+			return nil
+		}
+		blocks, err := sourceBlocks(filename)
+		if err != nil {
+			return err
+		}
+		if len(blocks) > 0 {
+			p.files[importName] = blocks
+		}
+		return nil
+	})
+}
+
+// Parses a source file into uncovered blocks, blocks and their statements are
+// counted the way cmd/cover instruments them
+func sourceBlocks(filename string) (map[blockKey]blockValue, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	c := &blockCounter{fset: fset, blocks: make(map[blockKey]blockValue)}
+	ast.Inspect(file, c.visit)
+	return c.blocks, nil
+}
+
+type blockCounter struct {
+	fset   *token.FileSet
+	blocks map[blockKey]blockValue
+}
+
+func (c *blockCounter) visit(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.BlockStmt:
+		// The body of a switch or select is a list of clauses
+		if len(n.List) > 0 {
+			switch n.List[0].(type) {
+			case *ast.CaseClause:
+				for _, stmt := range n.List {
+					clause := stmt.(*ast.CaseClause)
+					c.addList(clause.Colon+1, clause.End(), clause.Body)
+				}
+				return true
+			case *ast.CommClause:
+				for _, stmt := range n.List {
+					clause := stmt.(*ast.CommClause)
+					c.addList(clause.Colon+1, clause.End(), clause.Body)
+				}
+				return true
+			}
+		}
+		c.addList(n.Lbrace, n.Rbrace+1, n.List)
+	case *ast.IfStmt:
+		// An else if is instrumented as a block of its own
+		if elseIf, ok := n.Else.(*ast.IfStmt); ok {
+			c.addList(elseIf.Pos(), elseIf.End(), []ast.Stmt{elseIf})
+		}
+	case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+		// Empty ones are not instrumented
+		var body *ast.BlockStmt
+		switch n := n.(type) {
+		case *ast.SwitchStmt:
+			body = n.Body
+		case *ast.TypeSwitchStmt:
+			body = n.Body
+		case *ast.SelectStmt:
+			body = n.Body
+		}
+		if body == nil || len(body.List) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Splits a statement list into basic blocks at statements changing the flow
+// of control, an empty list is a block without statements
+func (c *blockCounter) addList(pos, end token.Pos, list []ast.Stmt) {
+	if len(list) == 0 {
+		c.add(pos, end, 0)
+		return
+	}
+	var stmts int32
+	start := list[0].Pos()
+	for i := 0; i < len(list); i++ {
+		stmt := list[i]
+		stmts++
+		if !endsBlock(stmt) {
+			continue
+		}
+		blockEnd := stmt.End()
+		// A label may be the target of a goto and starts a block unless it
+		// labels a control statement
+		if label, ok := stmt.(*ast.LabeledStmt); ok && !isControl(label.Stmt) {
+			blockEnd = label.Colon + 1
+			list = append(list[:i+1:i+1], append([]ast.Stmt{label.Stmt}, list[i+1:]...)...)
+		}
+		c.add(start, blockEnd, stmts)
+		stmts = 0
+		if i+1 < len(list) {
+			start = list[i+1].Pos()
+		}
+	}
+	if stmts > 0 {
+		c.add(start, list[len(list)-1].End(), stmts)
+	}
+}
+
+func (c *blockCounter) add(pos, end token.Pos, stmts int32) {
+	start, stop := c.fset.Position(pos), c.fset.Position(end)
+	key := blockKey{
+		startLine: int32(start.Line),
+		startCol:  int32(start.Column),
+		endLine:   int32(stop.Line),
+		endCol:    int32(stop.Column),
+	}
+	if _, ok := c.blocks[key]; !ok {
+		c.blocks[key] = blockValue{numStmt: stmts}
+	}
+}
+
+// Reports whether cmd/cover ends a basic block after the statement
+func endsBlock(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.BlockStmt, *ast.BranchStmt, *ast.ForStmt, *ast.IfStmt, *ast.LabeledStmt,
+		*ast.RangeStmt, *ast.SwitchStmt, *ast.SelectStmt, *ast.TypeSwitchStmt:
+		return true
+	case *ast.ExprStmt:
+		// Calls to panic change the flow
+		if call, ok := s.X.(*ast.CallExpr); ok {
+			if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == "panic" && len(call.Args) == 1 {
+				return true
+			}
+		}
+	}
+	var found bool
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func isControl(stmt ast.Stmt) bool {
+	switch stmt.(type) {
+	case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.SelectStmt, *ast.TypeSwitchStmt:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package coverreport
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/cover"
+
+	"github.com/timonwong/alauda-pipeline-cover/testdata"
+)
+
+func writeSource(t *testing.T, dir, name, source string) {
+	t.Helper()
+	filename := filepath.Join(dir, filepath.FromSlash(name))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o700))
+	assert.NoError(t, os.WriteFile(filename, []byte(source), 0o600))
+}
+
+func TestSourceBlocks(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "a.go", `package a
+
+func A(n int) int {
+	x := n
+	if n > 1 {
+		return 1
+	} else if n < 0 {
+		panic("negative")
+	}
+	switch n {
+	case 0:
+	case 1:
+		x++
+		x++
+	}
+loop:
+	x--
+	f := func() {}
+	f()
+	return x
+}
+`)
+	blocks, err := sourceBlocks(filepath.Join(dir, "a.go"))
+	assert.NoError(t, err)
+	var stmts []int32
+	for _, value := range blocks {
+		stmts = append(stmts, value.numStmt)
+	}
+	// x := n; if | return 1 | else if | panic | switch | case 0 | x++ x++ |
+	// loop: | x--; f := func | func body | f(); return x
+	assert.ElementsMatch(t, []int32{2, 1, 1, 1, 1, 0, 2, 1, 2, 0, 2}, stmts)
+}
+
+// The profile was written by go test -coverprofile for the source, only the
+// ends of blocks differ between cmd/cover versions
+func TestSourceBlocksMatchProfile(t *testing.T) {
+	profiles, err := cover.ParseProfiles(testdata.Filename("_greet/coverage.out"))
+	assert.NoError(t, err)
+	type start struct {
+		line, col int
+		numStmt   int
+	}
+	var expected []start
+	for _, block := range profiles[0].Blocks {
+		expected = append(expected, start{block.StartLine, block.StartCol, block.NumStmt})
+	}
+
+	blocks, err := sourceBlocks(testdata.Filename("_greet/greet.go"))
+	assert.NoError(t, err)
+	var actual []start
+	for key, value := range blocks {
+		actual = append(actual, start{int(key.startLine), int(key.startCol), int(value.numStmt)})
+	}
+	sort.Slice(actual, func(i, j int) bool {
+		return actual[i].line < actual[j].line || actual[i].line == actual[j].line && actual[i].col < actual[j].col
+	})
+	assert.Equal(t, expected, actual)
+}
+
+func TestAddMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "go.mod", "module example.com/app\n")
+	source := "package pkg\n\nfunc A() int {\n\treturn 1\n}\n"
+	writeSource(t, dir, "covered/a.go", source)
+	writeSource(t, dir, "untested/b.go", source)
+	writeSource(t, dir, "untested/b_test.go", source)
+	writeSource(t, dir, "untested/c_plan9.go", source)
+	writeSource(t, dir, "untested/d.go", "//go:build ignore\n\n"+source)
+	writeSource(t, dir, "untested/consts.go", "package pkg\n\nconst C = 1\n")
+	writeSource(t, dir, "mock/e.go", source)
+	writeSource(t, dir, "testdata/f.go", source)
+	writeSource(t, dir, "nested/go.mod", "module example.com/nested\n")
+	writeSource(t, dir, "nested/g.go", source)
+
+	profile := writeProfile(t, "mode: set\nexample.com/app/covered/a.go:3.14,5.2 1 1\n")
+	conf := &Configuration{
+		Exclusions: []string{"**/mock/**"},
+		SortBy:     SortByFilename,
+		Order:      OrderAsc,
+		SourceDir:  dir,
+		AddMissing: true,
+	}
+	report, err := GenerateReport(profile, conf, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []Summary{
+		{Name: "example.com/app/covered/a.go", Blocks: 1, Stmts: 1, BlockCoverage: 100, StmtCoverage: 100},
+		{Name: "example.com/app/untested/b.go", Blocks: 1, Stmts: 1, MissingBlocks: 1, MissingStmts: 1},
+	}, report.Files)
+	assert.EqualValues(t, 50, report.Total.StmtCoverage)
+}
@@ -0,0 +1,77 @@
+package coverreport
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Stdin is the coverprofile name reading from standard input
+const Stdin = "-"
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Opens a coverprofile file or stdin, gzip and zstd compressed profiles are
+// decompressed transparently
+func openProfile(filename string) (io.ReadCloser, error) {
+	f := io.NopCloser(os.Stdin)
+	if filename != Stdin {
+		var err error
+		f, err = os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := bufio.NewReader(f)
+	// Errors like EOF are left to the parser
+	magic, _ := r.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &profileReader{Reader: zr, closers: []io.Closer{zr, f}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &profileReader{Reader: zr, closers: []io.Closer{zstdCloser{zr}, f}}, nil
+	}
+	return &profileReader{Reader: r, closers: []io.Closer{f}}, nil
+}
+
+type profileReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *profileReader) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+type zstdCloser struct {
+	*zstd.Decoder
+}
+
+func (z zstdCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
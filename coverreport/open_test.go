@@ -0,0 +1,63 @@
+package coverreport
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/timonwong/alauda-pipeline-cover/testdata"
+)
+
+func compressSample(t *testing.T, filename string, compress func(w io.Writer) (io.WriteCloser, error)) string {
+	t.Helper()
+	data, err := os.ReadFile(testdata.Filename("sample_coverage.out"))
+	assert.NoError(t, err)
+
+	filename = filepath.Join(t.TempDir(), filename)
+	f, err := os.Create(filename)
+	assert.NoError(t, err)
+	defer f.Close()
+	w, err := compress(f)
+	assert.NoError(t, err)
+	_, err = w.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return filename
+}
+
+func TestCompressedReport(t *testing.T) {
+	conf := &Configuration{SortBy: SortByBlock, Order: OrderDesc}
+	expected, err := GenerateReport(testdata.Filename("sample_coverage.out"), conf, false)
+	assert.NoError(t, err)
+
+	gz := compressSample(t, "coverage.out.gz", func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	})
+	zst := compressSample(t, "coverage.out.zst", func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	})
+	for _, filename := range []string{gz, zst} {
+		report, err := GenerateReport(filename, conf, false)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, report, filename)
+	}
+}
+
+func TestStdinReport(t *testing.T) {
+	f, err := os.Open(testdata.Filename("sample_coverage.out"))
+	assert.NoError(t, err)
+	defer f.Close()
+
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+
+	report, err := GenerateReport(Stdin, &Configuration{SortBy: SortByBlock, Order: OrderDesc}, false)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 111, report.Total.Stmts)
+}
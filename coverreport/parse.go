@@ -0,0 +1,278 @@
+package coverreport
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+
+	"golang.org/x/tools/cover"
+)
+
+// Identifies a block in a file
+type blockKey struct {
+	startLine, startCol, endLine, endCol int32
+}
+
+type blockValue struct {
+	numStmt int32
+	count   int64
+}
+
+// Holds the unique blocks of every file in coverprofiles, memory grows with
+// the number of unique blocks rather than the number of profile lines
+type profileSet struct {
+	mode  string
+	files map[string]map[blockKey]blockValue
+}
+
+func newProfileSet() *profileSet {
+	return &profileSet{files: make(map[string]map[blockKey]blockValue)}
+}
+
+// Parses a coverprofile line by line into the set, blocks seen before are
+// merged like cover.ParseProfiles does. A directory is read as binary
+// coverage data written to GOCOVERDIR.
+func (p *profileSet) parseFile(filename string) error {
+	if filename != Stdin {
+		if info, err := os.Stat(filename); err == nil && info.IsDir() {
+			return p.parseCovDataDir(filename)
+		}
+	}
+	f, err := openProfile(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.parse(f)
+}
+
+func (p *profileSet) parse(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	var mode string
+	for lineNo := 1; s.Scan(); lineNo++ {
+		line := s.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if bytes.HasPrefix(line, []byte("mode: ")) {
+			// Concatenated profiles repeat the mode line
+			if err := p.setMode(string(line[len("mode: "):])); err != nil {
+				return fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			mode = p.mode
+			continue
+		}
+		if mode == "" {
+			return fmt.Errorf("bad mode line: %s", line)
+		}
+
+		name, key, value, err := parseProfileLine(line)
+		if err != nil {
+			return fmt.Errorf("line %d %q doesn't match expected format: %w", lineNo, line, err)
+		}
+		blocks, ok := p.files[string(name)]
+		if !ok {
+			blocks = make(map[blockKey]blockValue)
+			p.files[string(name)] = blocks
+		}
+		if err := p.addBlock(blocks, key, value); err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	return s.Err()
+}
+
+func (p *profileSet) setMode(mode string) error {
+	if mode == "" {
+		return errors.New("empty mode")
+	}
+	if p.mode != "" && p.mode != mode {
+		return fmt.Errorf("mode %q mismatches %q", mode, p.mode)
+	}
+	p.mode = mode
+	return nil
+}
+
+func (p *profileSet) addBlock(blocks map[blockKey]blockValue, key blockKey, value blockValue) error {
+	last, ok := blocks[key]
+	if !ok {
+		blocks[key] = value
+		return nil
+	}
+	if last.numStmt != value.numStmt {
+		return fmt.Errorf("inconsistent NumStmt: changed from %d to %d", last.numStmt, value.numStmt)
+	}
+	if p.mode == "set" {
+		last.count |= value.count
+	} else {
+		last.count += value.count
+	}
+	blocks[key] = last
+	return nil
+}
+
+// Calls fn for every file in name order with its blocks in position order
+func (p *profileSet) forEach(fn func(filename string, blocks []cover.ProfileBlock)) {
+	names := make([]string, 0, len(p.files))
+	for name := range p.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var blocks []cover.ProfileBlock
+	for _, name := range names {
+		blocks = blocks[:0]
+		for key, value := range p.files[name] {
+			blocks = append(blocks, cover.ProfileBlock{
+				StartLine: int(key.startLine),
+				StartCol:  int(key.startCol),
+				EndLine:   int(key.endLine),
+				EndCol:    int(key.endCol),
+				NumStmt:   int(value.numStmt),
+				Count:     int(value.count),
+			})
+		}
+		sort.Slice(blocks, func(i, j int) bool {
+			bi, bj := blocks[i], blocks[j]
+			return bi.StartLine < bj.StartLine || bi.StartLine == bj.StartLine && bi.StartCol < bj.StartCol
+		})
+		fn(name, blocks)
+	}
+}
+
+// Parses a line in the format name.go:line.column,line.column numberOfStatements count
+func parseProfileLine(line []byte) (name []byte, key blockKey, value blockValue, err error) {
+	end := len(line)
+	var count int64
+	if count, end, err = parseField(line, end, ' '); err != nil {
+		return
+	}
+	value.count = count
+	var n int64
+	if n, end, err = parseField(line, end, ' '); err != nil {
+		return
+	}
+	value.numStmt = int32(n)
+	if n, end, err = parseField(line, end, '.'); err != nil {
+		return
+	}
+	key.endCol = int32(n)
+	if n, end, err = parseField(line, end, ','); err != nil {
+		return
+	}
+	key.endLine = int32(n)
+	if n, end, err = parseField(line, end, '.'); err != nil {
+		return
+	}
+	key.startCol = int32(n)
+	if n, end, err = parseField(line, end, ':'); err != nil {
+		return
+	}
+	key.startLine = int32(n)
+	if end == 0 {
+		err = errors.New("missing file name")
+		return
+	}
+	return line[:end], key, value, nil
+}
+
+// Parses the number between the last sep before end and end
+func parseField(line []byte, end int, sep byte) (n int64, start int, err error) {
+	i := bytes.LastIndexByte(line[:end], sep)
+	if i < 0 {
+		return 0, 0, fmt.Errorf("missing %q", sep)
+	}
+	n, err = strconv.ParseInt(string(line[i+1:end]), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, i, nil
+}
+
+// Merges the blocks of another set into the set
+func (p *profileSet) merge(o *profileSet) error {
+	if o.mode == "" {
+		return nil
+	}
+	if err := p.setMode(o.mode); err != nil {
+		return err
+	}
+	for name, other := range o.files {
+		blocks, ok := p.files[name]
+		if !ok {
+			p.files[name] = other
+			continue
+		}
+		for key, value := range other {
+			if err := p.addBlock(blocks, key, value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Parses coverprofiles with a pool of workers, each worker aggregates into
+// its own set and the sets are merged in worker order. Merging is
+// commutative so the result does not depend on scheduling.
+func parseProfiles(coverprofiles []string, workers int) (*profileSet, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(coverprofiles) {
+		workers = len(coverprofiles)
+	}
+	if workers <= 1 {
+		p := newProfileSet()
+		for _, coverprofile := range coverprofiles {
+			if err := p.parseFile(coverprofile); err != nil {
+				return nil, fmt.Errorf("%s: %w", coverprofile, err)
+			}
+		}
+		return p, nil
+	}
+
+	sets := make([]*profileSet, workers)
+	errs := make([]error, len(coverprofiles))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := range sets {
+		sets[w] = newProfileSet()
+		wg.Add(1)
+		go func(p *profileSet) {
+			defer wg.Done()
+			for i := range jobs {
+				if err := p.parseFile(coverprofiles[i]); err != nil {
+					errs[i] = fmt.Errorf("%s: %w", coverprofiles[i], err)
+				}
+			}
+		}(sets[w])
+	}
+	for i := range coverprofiles {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Report the error of the first failed profile
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	merged := sets[0]
+	for _, p := range sets[1:] {
+		if err := merged.merge(p); err != nil {
+			return nil, err
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,211 @@
+package coverreport
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/cover"
+
+	"github.com/timonwong/alauda-pipeline-cover/testdata"
+)
+
+func parseProfileSet(t *testing.T, profile string) []*cover.Profile {
+	t.Helper()
+	p := newProfileSet()
+	assert.NoError(t, p.parse(strings.NewReader(profile)))
+	var profiles []*cover.Profile
+	p.forEach(func(filename string, blocks []cover.ProfileBlock) {
+		profiles = append(profiles, &cover.Profile{
+			FileName: filename,
+			Mode:     p.mode,
+			Blocks:   append([]cover.ProfileBlock(nil), blocks...),
+		})
+	})
+	return profiles
+}
+
+func TestParseMatchesCover(t *testing.T) {
+	expected, err := cover.ParseProfiles(testdata.Filename("sample_coverage.out"))
+	assert.NoError(t, err)
+	data, err := os.ReadFile(testdata.Filename("sample_coverage.out"))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, parseProfileSet(t, string(data)))
+}
+
+func TestParseDuplicateBlocks(t *testing.T) {
+	profiles := parseProfileSet(t, `mode: set
+a.go:1.1,2.2 1 0
+a.go:1.1,2.2 1 1
+a.go:3.1,4.2 2 0
+mode: set
+a.go:3.1,4.2 2 0
+`)
+	assert.Equal(t, []*cover.Profile{{FileName: "a.go", Mode: "set", Blocks: []cover.ProfileBlock{
+		{StartLine: 1, StartCol: 1, EndLine: 2, EndCol: 2, NumStmt: 1, Count: 1},
+		{StartLine: 3, StartCol: 1, EndLine: 4, EndCol: 2, NumStmt: 2, Count: 0},
+	}}}, profiles)
+
+	profiles = parseProfileSet(t, "mode: count\na.go:1.1,2.2 1 3\na.go:1.1,2.2 1 4\n")
+	assert.Equal(t, 7, profiles[0].Blocks[0].Count)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, profile := range []string{
+		"a.go:1.1,2.2 1 0\n",
+		"mode: set\na.go:1.1,2.2 1\n",
+		"mode: set\n:1.1,2.2 1 0\n",
+		"mode: set\na.go:1.1,2.2 1 0\na.go:1.1,2.2 2 0\n",
+		"mode: set\nmode: count\n",
+	} {
+		assert.Error(t, newProfileSet().parse(strings.NewReader(profile)), profile)
+	}
+}
+
+// Writes a profile of files*blocks unique blocks repeated times, like the
+// merged profile of tests run with -coverpkg=./...
+func writeSyntheticProfile(b testing.TB, files, blocks, repeats int) string {
+	b.Helper()
+	filename := filepath.Join(b.TempDir(), "coverage.out")
+	f, err := os.Create(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "mode: atomic")
+	for r := 0; r < repeats; r++ {
+		for i := 0; i < files; i++ {
+			for j := 0; j < blocks; j++ {
+				fmt.Fprintf(w, "example.com/pkg%d/file%d.go:%d.2,%d.16 %d %d\n", i/10, i, j*3+1, j*3+2, j%5+1, (r+j)%3)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return filename
+}
+
+// Returns the bytes allocated by generating the report of filename
+func allocatedBytes(t *testing.T, filename string) uint64 {
+	t.Helper()
+	conf := &Configuration{SortBy: SortByPackage, Order: OrderAsc}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	if _, err := GenerateReport(filename, conf, true); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+// Memory is bounded by the unique blocks, lines repeating blocks allocate nothing
+func TestParseMemoryBounded(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes a 200k lines profile")
+	}
+	once := allocatedBytes(t, writeSyntheticProfile(t, 100, 200, 1))
+	repeated := allocatedBytes(t, writeSyntheticProfile(t, 100, 200, 10))
+	assert.Less(t, float64(repeated), 1.1*float64(once), "allocated %d bytes once, %d bytes repeated", once, repeated)
+}
+
+// 200k unique blocks, 2M lines when repeated
+const benchFiles, benchBlocks, benchRepeats = 1000, 200, 10
+
+// Memory per op stays the same however many times blocks are repeated, as
+// asserted by TestParseMemoryBounded
+func BenchmarkGenerateReport(b *testing.B) {
+	for _, repeats := range []int{1, benchRepeats} {
+		b.Run(fmt.Sprintf("lines=%d", benchFiles*benchBlocks*repeats), func(b *testing.B) {
+			filename := writeSyntheticProfile(b, benchFiles, benchBlocks, repeats)
+			conf := &Configuration{SortBy: SortByPackage, Order: OrderAsc}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := GenerateReport(filename, conf, true); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// The baseline loading every block into memory
+func BenchmarkParseProfiles(b *testing.B) {
+	filename := writeSyntheticProfile(b, benchFiles, benchBlocks, benchRepeats)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := cover.ParseProfiles(filename); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Splits the sample profile into profiles of at most size blocks
+func splitSampleProfile(t testing.TB, size int) []string {
+	t.Helper()
+	data, err := os.ReadFile(testdata.Filename("sample_coverage.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	dir := t.TempDir()
+	var filenames []string
+	for i := 1; i < len(lines); i += size {
+		end := i + size
+		if end > len(lines) {
+			end = len(lines)
+		}
+		filename := filepath.Join(dir, fmt.Sprintf("coverage%d.out", i))
+		content := lines[0] + "\n" + strings.Join(lines[i:end], "\n") + "\n"
+		if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames
+}
+
+func TestGenerateMergedReport(t *testing.T) {
+	assert := assert.New(t)
+	expected, err := GenerateReport(testdata.Filename("sample_coverage.out"), &Configuration{SortBy: SortByBlock, Order: OrderDesc}, false)
+	assert.NoError(err)
+
+	filenames := splitSampleProfile(t, 7)
+	for _, workers := range []int{1, 4, 64} {
+		report, err := GenerateMergedReport(filenames, &Configuration{SortBy: SortByBlock, Order: OrderDesc, Workers: workers}, false)
+		assert.NoError(err)
+		assert.Equal(expected, report, "workers=%d", workers)
+	}
+
+	_, err = GenerateMergedReport(append(filenames, "../xxx.out"), &Configuration{SortBy: SortByBlock, Order: OrderDesc, Workers: 4}, false)
+	assert.Error(err)
+}
+
+func BenchmarkGenerateMergedReport(b *testing.B) {
+	filenames := make([]string, 0, 32)
+	for i := 0; i < cap(filenames); i++ {
+		filenames = append(filenames, writeSyntheticProfile(b, 100, 200, 2))
+	}
+	for _, workers := range []int{1, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			conf := &Configuration{SortBy: SortByPackage, Order: OrderAsc, Workers: workers}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := GenerateMergedReport(filenames, conf, true); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -32,6 +32,27 @@ type Configuration struct {
 	Exclusions []string
 	SortBy     string
 	Order      string
+	// Tree rolls up coverage into parent directories
+	Tree bool
+	// Depth collapses tree nodes deeper than it into their ancestor, zero means unlimited
+	Depth int
+	// Components groups coverage by component instead of file or package if not empty
+	Components []Component
+	// Workers parsing coverprofiles concurrently, zero means GOMAXPROCS
+	Workers int
+	// WeakHits counts covered blocks executed fewer times than it as weakly
+	// covered in count and atomic mode, zero disables the metric
+	WeakHits int
+	// Hotspots is the number of most and least executed blocks to report
+	Hotspots int
+	// SourceDir is the directory of the main module, required by VerifySource
+	// and AddMissing
+	SourceDir string
+	// VerifySource checks blocks of the main module against its sources
+	VerifySource bool
+	// AddMissing adds source files of the main module missing from the
+	// coverprofiles as uncovered
+	AddMissing bool
 }
 
 // Summary is coverage summary for a file or module
@@ -39,40 +60,137 @@ type Summary struct {
 	Name                                       string
 	Blocks, Stmts, MissingBlocks, MissingStmts int64
 	BlockCoverage, StmtCoverage                float64
+	// Covered blocks and their statements executed fewer than WeakHits times
+	WeakBlocks, WeakStmts int64
+	// Level is the depth of the row in a tree report
+	Level int
 }
 
 // Report of the coverage results
 type Report struct {
+	Item  string    // What is covered by a row of Files: File, Package or Component
 	Total Summary   // Global coverage
 	Files []Summary // Coverage by file
+	// Thresholds of rows overriding the default threshold
+	Thresholds map[string]float64
+	// Mode of the coverprofiles: set, count or atomic
+	Mode string
+	// WeakHits of the configuration, zero if disabled or in set mode
+	WeakHits int
+	// Most and least executed blocks, nil unless configured
+	Hotspots *Hotspots
+	// Files not matching their sources, if verified
+	Stale []StaleFile
 }
 
-// GenerateReport generates a coverage report given the coverage profile file, and the following configurations:
+// GenerateReport generates a coverage report given the coverage profile file (Stdin for standard input,
+// gzip and zstd compressed files are supported, a directory is read as GOCOVERDIR binary coverage data),
+// and the following configurations:
 // exclusions: packages to be excluded (if a package is excluded, all its subpackages are excluded as well)
 // sortBy: the order in which the files will be sorted in the report (see sortResults)
 // order: the direction of the the sorting
 func GenerateReport(coverprofile string, conf *Configuration, packages bool) (*Report, error) {
-	profiles, err := cover.ParseProfiles(coverprofile)
+	return GenerateMergedReport([]string{coverprofile}, conf, packages)
+}
+
+// GenerateMergedReport generates a coverage report like GenerateReport from
+// several coverprofiles, which are parsed concurrently and merged
+func GenerateMergedReport(coverprofiles []string, conf *Configuration, packages bool) (*Report, error) {
+	profiles, err := parseProfiles(coverprofiles, conf.Workers)
 	if err != nil {
 		return nil, fmt.Errorf("invalid coverprofile: %w", err)
 	}
-	total := &accumulator{name: "Total"}
+	// Every covered block is hit once in set mode
+	weakHits := int64(conf.WeakHits)
+	if profiles.mode == "set" {
+		weakHits = 0
+	}
+	var hotspots *hotspotCollector
+	if conf.Hotspots > 0 {
+		hotspots = &hotspotCollector{}
+	}
+	var sources *sourceResolver
+	if conf.VerifySource || conf.AddMissing {
+		if sources, err = newSourceResolver(conf.SourceDir); err != nil {
+			return nil, err
+		}
+	}
+	if conf.AddMissing {
+		if err := profiles.addMissingFiles(sources, conf.Exclusions); err != nil {
+			return nil, fmt.Errorf("unable to add missing files: %w", err)
+		}
+	}
+	var stale []StaleFile
+	total := &accumulator{name: "Total", weakHits: weakHits}
 	files := make(map[string]*accumulator)
-	for _, profile := range profiles {
-		if isExcluded(profile.FileName, conf.Exclusions) {
-			continue
+	profiles.forEach(func(fileName string, blocks []cover.ProfileBlock) {
+		if isExcluded(fileName, conf.Exclusions) {
+			return
+		}
+		if conf.VerifySource {
+			if reason := sources.verify(fileName, blocks); reason != "" {
+				stale = append(stale, StaleFile{Name: fileName, Reason: reason})
+			}
+		}
+		var filename string
+		if len(conf.Components) > 0 {
+			filename = componentOf(fileName, conf.Components)
+		} else {
+			filename = normalizeName(fileName, conf.Root, packages)
 		}
-		filename := normalizeName(profile.FileName, conf.Root, packages)
 		fileCover, ok := files[filename]
 		if !ok {
 			// Create new accumulator
-			fileCover = &accumulator{name: filename}
+			fileCover = &accumulator{name: filename, weakHits: weakHits}
 			files[filename] = fileCover
 		}
-		total.addAll(profile.Blocks)
-		fileCover.addAll(profile.Blocks)
+		total.addAll(blocks)
+		fileCover.addAll(blocks)
+		if hotspots != nil {
+			hotspots.addAll(normalizeName(fileName, conf.Root, false), blocks)
+		}
+	})
+	var report *Report
+	switch {
+	case len(conf.Components) > 0:
+		report, err = makeReport(total, files, conf.SortBy, conf.Order)
+		if err == nil {
+			report.Item = "Component"
+			report.Thresholds = componentThresholds(conf.Components)
+		}
+	case conf.Tree:
+		report, err = makeTreeReport(total, files, conf)
+	default:
+		report, err = makeReport(total, files, conf.SortBy, conf.Order)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if report.Item == "" {
+		report.Item = itemName(packages)
 	}
-	return makeReport(total, files, conf.SortBy, conf.Order)
+	report.Mode = profiles.mode
+	report.WeakHits = int(weakHits)
+	report.Stale = stale
+	if hotspots != nil {
+		report.Hotspots = hotspots.results(conf.Hotspots)
+	}
+	return report, nil
+}
+
+func itemName(packages bool) string {
+	if packages {
+		return "Package"
+	}
+	return "File"
+}
+
+// Threshold returns the threshold of a row, or fallback if it has none
+func (r *Report) Threshold(name string, fallback float64) float64 {
+	if threshold, ok := r.Thresholds[name]; ok {
+		return threshold
+	}
+	return fallback
 }
 
 // Removes root dir part if configured to do so
@@ -114,10 +232,28 @@ func makeReport(total *accumulator, files map[string]*accumulator, sortBy, order
 	}, nil
 }
 
+// Creates a Report struct with files rolled up into their parent directories
+func makeTreeReport(total *accumulator, files map[string]*accumulator, conf *Configuration) (*Report, error) {
+	if len(files) == 0 {
+		return makeReport(total, files, conf.SortBy, conf.Order)
+	}
+	fileReports, err := makeTree(files, conf.Depth).flatten(conf.SortBy, conf.Order, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Report{
+		Total: total.results(),
+		Files: fileReports,
+	}, nil
+}
+
 // Accumulates the coverage of a file and returns a summary
 type accumulator struct {
 	name                                       string
 	blocks, stmts, coveredBlocks, coveredStmts int64
+	// Covered blocks hit fewer than weakHits times are weak
+	weakHits              int64
+	weakBlocks, weakStmts int64
 }
 
 // Accumulates a profile block
@@ -127,6 +263,10 @@ func (a *accumulator) add(block cover.ProfileBlock) {
 	if block.Count > 0 {
 		a.coveredBlocks++
 		a.coveredStmts += int64(block.NumStmt)
+		if int64(block.Count) < a.weakHits {
+			a.weakBlocks++
+			a.weakStmts += int64(block.NumStmt)
+		}
 	}
 }
 
@@ -136,6 +276,16 @@ func (a *accumulator) addAll(blocks []cover.ProfileBlock) {
 	}
 }
 
+// Accumulates the coverage of another accumulator
+func (a *accumulator) merge(o *accumulator) {
+	a.blocks += o.blocks
+	a.stmts += o.stmts
+	a.coveredBlocks += o.coveredBlocks
+	a.coveredStmts += o.coveredStmts
+	a.weakBlocks += o.weakBlocks
+	a.weakStmts += o.weakStmts
+}
+
 // Creates a summary with the accumulated values
 func (a *accumulator) results() Summary {
 	return Summary{
@@ -146,6 +296,8 @@ func (a *accumulator) results() Summary {
 		MissingStmts:  a.stmts - a.coveredStmts,
 		BlockCoverage: float64(a.coveredBlocks) / float64(a.blocks) * 100,
 		StmtCoverage:  float64(a.coveredStmts) / float64(a.stmts) * 100,
+		WeakBlocks:    a.weakBlocks,
+		WeakStmts:     a.weakStmts,
 	}
 }
 
@@ -71,3 +71,34 @@ func TestInvalidCoverProfile(t *testing.T) {
 	_, err := GenerateReport("../xxx.out", &Configuration{SortBy: SortByBlock, Order: OrderDesc}, false)
 	assert.Error(t, err)
 }
+
+func TestTreeReport(t *testing.T) {
+	assert := assert.New(t)
+	conf := &Configuration{SortBy: SortByPackage, Order: OrderAsc, Tree: true}
+	report, err := GenerateReport(testdata.Filename("sample_coverage.out"), conf, true)
+	assert.NoError(err)
+	assert.Len(report.Files, 2)
+	root, sub := report.Files[0], report.Files[1]
+	assert.Equal("github.com/mcubik/goverreport", root.Name)
+	assert.Equal(0, root.Level)
+	assert.Equal(report.Total.Stmts, root.Stmts)
+	assert.Equal(report.Total.MissingStmts, root.MissingStmts)
+	assert.Equal("github.com/mcubik/goverreport/report", sub.Name)
+	assert.Equal(1, sub.Level)
+	assert.EqualValues(67, sub.Stmts)
+
+	conf.Depth = 1
+	report, err = GenerateReport(testdata.Filename("sample_coverage.out"), conf, false)
+	assert.NoError(err)
+	names := make([]string, 0, len(report.Files))
+	for _, file := range report.Files {
+		assert.LessOrEqual(file.Level, 1)
+		names = append(names, file.Name)
+	}
+	assert.Equal([]string{
+		"github.com/mcubik/goverreport",
+		"github.com/mcubik/goverreport/main.go",
+		"github.com/mcubik/goverreport/report",
+	}, names)
+	assert.Equal(report.Total.Stmts, report.Files[0].Stmts)
+}
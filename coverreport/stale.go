@@ -0,0 +1,125 @@
+package coverreport
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/cover"
+)
+
+// StaleFile is a file of the coverprofiles that does not match its source
+type StaleFile struct {
+	Name   string
+	Reason string
+}
+
+func (s StaleFile) String() string {
+	return s.Name + ": " + s.Reason
+}
+
+// SourceDirError is returned if the source directory is not the root of a
+// module, which is a mistake of configuration rather than of coverprofiles.
+type SourceDirError struct {
+	Dir string
+	Err error
+}
+
+func (e *SourceDirError) Error() string {
+	return fmt.Sprintf("invalid source dir %s: %v", e.Dir, e.Err)
+}
+
+func (e *SourceDirError) Unwrap() error {
+	return e.Err
+}
+
+// Maps file names of coverprofiles, which are import paths, to source files of
+// the main module
+type sourceResolver struct {
+	dir    string
+	module string
+}
+
+// Reads the module path from go.mod in dir
+func newSourceResolver(dir string) (*sourceResolver, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, &SourceDirError{Dir: dir, Err: fmt.Errorf("unable to read module: %w", err)}
+	}
+	module := modulePath(data)
+	if module == "" {
+		return nil, &SourceDirError{Dir: dir, Err: errors.New("no module path in go.mod")}
+	}
+	return &sourceResolver{dir: dir, module: module}, nil
+}
+
+// Parses the module directive of a go.mod file
+func modulePath(data []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if !strings.HasPrefix(line, "module") {
+			continue
+		}
+		path := strings.TrimSpace(strings.TrimPrefix(line, "module"))
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			path = unquoted
+		}
+		return path
+	}
+	return ""
+}
+
+// Returns the source file of a coverprofile file name, false if the file is
+// not in the main module
+func (r *sourceResolver) path(name string) (string, bool) {
+	if filepath.IsAbs(name) {
+		return name, true
+	}
+	if rel := strings.TrimPrefix(name, r.module+"/"); rel != name {
+		return filepath.Join(r.dir, filepath.FromSlash(rel)), true
+	}
+	return "", false
+}
+
+// Checks that the file exists and every block is within its lines and columns,
+// returns an empty reason if the blocks match the source
+func (r *sourceResolver) verify(name string, blocks []cover.ProfileBlock) string {
+	filename, ok := r.path(name)
+	if !ok {
+		// Files of other modules cannot be verified
+		return ""
+	}
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return "file does not exist"
+	}
+	if err != nil {
+		return err.Error()
+	}
+
+	// Columns are 1-based bytes, a block may end right after the last byte of a line
+	lines := bytes.Split(data, []byte("\n"))
+	lineEnd := func(line int) int {
+		return len(lines[line-1]) + 1
+	}
+	for _, b := range blocks {
+		switch {
+		case b.StartLine < 1 || b.EndLine > len(lines):
+			return fmt.Sprintf("block %d.%d,%d.%d is out of %d lines", b.StartLine, b.StartCol, b.EndLine, b.EndCol, len(lines))
+		case b.EndLine < b.StartLine || b.EndLine == b.StartLine && b.EndCol < b.StartCol:
+			return fmt.Sprintf("block %d.%d,%d.%d ends before it starts", b.StartLine, b.StartCol, b.EndLine, b.EndCol)
+		case b.StartCol < 1 || b.StartCol > lineEnd(b.StartLine) || b.EndCol < 1 || b.EndCol > lineEnd(b.EndLine):
+			return fmt.Sprintf("block %d.%d,%d.%d is out of line length", b.StartLine, b.StartCol, b.EndLine, b.EndCol)
+		}
+	}
+	return ""
+}
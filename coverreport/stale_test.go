@@ -0,0 +1,53 @@
+package coverreport
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModulePath(t *testing.T) {
+	assert.Equal(t, "example.com/app", modulePath([]byte("// app\nmodule example.com/app // comment\n\ngo 1.16\n")))
+	assert.Equal(t, "example.com/app", modulePath([]byte(`module "example.com/app"`)))
+	assert.Equal(t, "", modulePath([]byte("go 1.16\n")))
+}
+
+func TestStaleReport(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0o600))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "pkg"), 0o700))
+	source := "package pkg\n\nfunc A() int {\n\treturn 1\n}\n"
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "pkg", name), []byte(source), 0o600))
+	}
+
+	profile := writeProfile(t, `mode: set
+example.com/app/pkg/a.go:3.14,5.2 1 1
+example.com/app/pkg/b.go:3.14,9.2 1 1
+example.com/app/pkg/c.go:3.14,4.20 1 1
+example.com/app/pkg/d.go:3.14,5.2 1 1
+example.com/other/e.go:3.14,5.2 1 1
+`)
+	conf := &Configuration{SortBy: SortByFilename, Order: OrderAsc, SourceDir: dir, VerifySource: true}
+	report, err := GenerateReport(profile, conf, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []StaleFile{
+		{Name: "example.com/app/pkg/b.go", Reason: "block 3.14,9.2 is out of 6 lines"},
+		{Name: "example.com/app/pkg/c.go", Reason: "block 3.14,4.20 is out of line length"},
+		{Name: "example.com/app/pkg/d.go", Reason: "file does not exist"},
+	}, report.Stale)
+
+	conf.VerifySource = false
+	report, err = GenerateReport(profile, conf, false)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Stale)
+
+	conf.SourceDir, conf.VerifySource = t.TempDir(), true
+	_, err = GenerateReport(profile, conf, false)
+	var sourceDirErr *SourceDirError
+	if assert.ErrorAs(t, err, &sourceDirErr) {
+		assert.Equal(t, conf.SourceDir, sourceDirErr.Dir)
+	}
+}
@@ -0,0 +1,68 @@
+package coverreport
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Filter returns a copy of the report without rows of fewer than minStmts
+// statements, the total is kept as is
+func (r *Report) Filter(minStmts int64) *Report {
+	filtered := *r
+	filtered.Files = make([]Summary, 0, len(r.Files))
+	for _, row := range r.Files {
+		if row.Stmts >= minStmts {
+			filtered.Files = append(filtered.Files, row)
+		}
+	}
+	return &filtered
+}
+
+// Top returns a copy of the report with the n worst rows by the sortBy column,
+// worst first: most missing for the missing columns, lowest coverage for the
+// coverage columns and most missing statements otherwise. Of a tree report
+// only the leaves are ranked, listed with their full names.
+func (r *Report) Top(n int, sortBy string) *Report {
+	// Worse rows have higher badness
+	var badness func(c Summary) float64
+	switch sortBy {
+	case SortByMissingBlocks:
+		badness = func(c Summary) float64 { return float64(c.MissingBlocks) }
+	case SortByBlock, SortByBlockCoverage:
+		badness = func(c Summary) float64 { return -c.BlockCoverage }
+	case SortByStmt, SortByStmtCoverage:
+		badness = func(c Summary) float64 { return -c.StmtCoverage }
+	default:
+		badness = func(c Summary) float64 { return float64(c.MissingStmts) }
+	}
+	rows := make([]Summary, 0, len(r.Files))
+	for i, row := range r.Files {
+		// Directories are followed by their deeper children
+		if i+1 < len(r.Files) && r.Files[i+1].Level > row.Level {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if bi, bj := badness(rows[i]), badness(rows[j]); bi != bj {
+			return bi > bj
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	if n < len(rows) {
+		rows = rows[:n]
+	}
+	for i := range rows {
+		rows[i].Level = 0
+	}
+	top := *r
+	top.Files = rows
+	return &top
+}
+
+// FormatTotal formats the total coverage as a compact line
+func FormatTotal(total Summary) string {
+	return fmt.Sprintf("Total: %.2f%% of %d statements covered (%d missing), %.2f%% of %d blocks covered (%d missing)",
+		total.StmtCoverage, total.Stmts, total.MissingStmts,
+		total.BlockCoverage, total.Blocks, total.MissingBlocks)
+}
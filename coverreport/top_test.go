@@ -0,0 +1,41 @@
+package coverreport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTop(t *testing.T) {
+	a := Summary{Name: "a", Stmts: 100, MissingStmts: 10, MissingBlocks: 5, StmtCoverage: 90, BlockCoverage: 80}
+	b := Summary{Name: "b", Stmts: 10, MissingStmts: 5, MissingBlocks: 6, StmtCoverage: 50, BlockCoverage: 40}
+	c := Summary{Name: "c", Stmts: 2, MissingStmts: 2, MissingBlocks: 1, StmtCoverage: 0, BlockCoverage: 0}
+	d := Summary{Name: "d", Stmts: 20, MissingStmts: 10, MissingBlocks: 1, StmtCoverage: 50, BlockCoverage: 50}
+	report := &Report{Item: "File", Total: Summary{Name: "Total"}, Files: []Summary{a, b, c, d}}
+
+	assert.Equal(t, []Summary{a, d}, report.Top(2, SortByFilename).Files)
+	assert.Equal(t, []Summary{a, d}, report.Top(2, SortByMissingStmts).Files)
+	assert.Equal(t, []Summary{b, a}, report.Top(2, SortByMissingBlocks).Files)
+	assert.Equal(t, []Summary{c, b, d}, report.Top(3, SortByStmtCoverage).Files)
+	assert.Equal(t, []Summary{c, b, d, a}, report.Top(10, SortByBlock).Files)
+	assert.Equal(t, []Summary{a, b, c, d}, report.Files)
+
+	filtered := report.Filter(10)
+	assert.Equal(t, []Summary{a, b, d}, filtered.Files)
+	assert.Equal(t, report.Total, filtered.Total)
+	assert.Equal(t, []Summary{b, d}, filtered.Top(2, SortByStmt).Files)
+}
+
+func TestTopTree(t *testing.T) {
+	report := &Report{Files: []Summary{
+		{Name: "pkg", MissingStmts: 3},
+		{Name: "pkg/a", MissingStmts: 1, Level: 1},
+		{Name: "pkg/b", MissingStmts: 2, Level: 1},
+	}}
+	assert.Equal(t, []Summary{{Name: "pkg/b", MissingStmts: 2}}, report.Top(1, SortByPackage).Files)
+}
+
+func TestFormatTotal(t *testing.T) {
+	total := Summary{Name: "Total", Blocks: 4, Stmts: 10, MissingBlocks: 1, MissingStmts: 2, BlockCoverage: 75, StmtCoverage: 80}
+	assert.Equal(t, "Total: 80.00% of 10 statements covered (2 missing), 75.00% of 4 blocks covered (1 missing)", FormatTotal(total))
+}
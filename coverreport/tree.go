@@ -0,0 +1,105 @@
+package coverreport
+
+import (
+	"path"
+	"strings"
+)
+
+// A directory in the package tree, its accumulator holds the coverage of
+// everything below it
+type treeNode struct {
+	acc      *accumulator
+	level    int
+	children map[string]*treeNode
+}
+
+// Builds a tree rooted at the common directory of all files or packages,
+// nodes deeper than depth are collapsed into their ancestor at depth
+func makeTree(files map[string]*accumulator, depth int) *treeNode {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	rootSegments := commonDir(names)
+	root := &treeNode{acc: &accumulator{name: path.Join(rootSegments...)}}
+	if len(rootSegments) == 0 {
+		root.acc.name = "."
+	}
+	for name, fileCover := range files {
+		node := root
+		node.acc.merge(fileCover)
+		for _, segment := range splitPath(name)[len(rootSegments):] {
+			if depth > 0 && node.level >= depth {
+				break
+			}
+			child, ok := node.children[segment]
+			if !ok {
+				if node.children == nil {
+					node.children = make(map[string]*treeNode)
+				}
+				child = &treeNode{
+					acc:   &accumulator{name: path.Join(node.acc.name, segment)},
+					level: node.level + 1,
+				}
+				node.children[segment] = child
+			}
+			child.acc.merge(fileCover)
+			node = child
+		}
+	}
+	return root
+}
+
+// Appends the node and its descendants in depth-first order, siblings are
+// sorted by the given column and direction
+func (n *treeNode) flatten(sortBy, order string, out []Summary) ([]Summary, error) {
+	out = append(out, n.summary())
+	children := make([]Summary, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child.summary())
+	}
+	if err := sortResults(children, sortBy, order); err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		var err error
+		out, err = n.children[path.Base(child.Name)].flatten(sortBy, order, out)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (n *treeNode) summary() Summary {
+	s := n.acc.results()
+	s.Level = n.level
+	return s
+}
+
+// Returns the path segments of the longest directory shared by all names
+func commonDir(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	common := splitPath(names[0])
+	for _, name := range names[1:] {
+		segments := splitPath(name)
+		i := 0
+		for i < len(common) && i < len(segments) && common[i] == segments[i] {
+			i++
+		}
+		common = common[:i]
+	}
+	return common
+}
+
+func splitPath(name string) []string {
+	var segments []string
+	for _, segment := range strings.Split(name, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
@@ -3,47 +3,151 @@ package coverreport
 import (
 	"fmt"
 	"io"
+	"path"
+	"strings"
 
 	"github.com/olekukonko/tablewriter"
 )
 
+const (
+	FormatTable    = "table"
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+	FormatTSV      = "tsv"
+)
+
+// PrintOptions controls how a report is printed
+type PrintOptions struct {
+	Format   string
+	Packages bool
+	// Rows with statement coverage below Threshold are highlighted, zero disables highlighting
+	Threshold float64
+	// Color highlights table rows by CoverageColor with ANSI escape codes
+	Color bool
+}
+
+// Print prints the report in the given format
+func Print(report *Report, writer io.Writer, opts PrintOptions) error {
+	switch opts.Format {
+	case FormatTable:
+		printTable(report, writer, opts)
+	case FormatMarkdown:
+		PrintMarkdown(report, writer, opts.Packages, opts.Threshold)
+	case FormatCSV:
+		return PrintCSV(report, writer, ',')
+	case FormatTSV:
+		return PrintCSV(report, writer, '\t')
+	default:
+		return fmt.Errorf("invalid format %q", opts.Format)
+	}
+	return nil
+}
+
 // PrintTable prints the report to the terminal
 func PrintTable(report *Report, writer io.Writer, packages bool) {
-	item := "File"
-	if packages {
-		item = "Package"
-	}
+	printTable(report, writer, PrintOptions{Packages: packages})
+}
+
+func printTable(report *Report, writer io.Writer, opts PrintOptions) {
 	table := tablewriter.NewWriter(writer)
-	table.SetColumnAlignment([]int{
-		tablewriter.ALIGN_LEFT,
-		tablewriter.ALIGN_RIGHT,
-		tablewriter.ALIGN_RIGHT,
-		tablewriter.ALIGN_RIGHT,
-		tablewriter.ALIGN_RIGHT,
-		tablewriter.ALIGN_RIGHT,
-		tablewriter.ALIGN_RIGHT,
-	})
+	header := makeHeader(report, opts.Packages)
+	alignment := []int{tablewriter.ALIGN_LEFT}
+	for range header[1:] {
+		alignment = append(alignment, tablewriter.ALIGN_RIGHT)
+	}
+	table.SetColumnAlignment(alignment)
 	table.SetFooterAlignment(tablewriter.ALIGN_RIGHT)
-	table.SetHeader([]string{
-		item, "Blocks", "Missing", "Stmts", "Missing",
-		"Block cover %", "Stmt cover %"})
+	table.SetHeader(header)
 	for _, fileCoverage := range report.Files {
-		table.Append(makeRow(fileCoverage))
+		row := makeRow(report, fileCoverage)
+		if opts.Color {
+			color := CoverageColor(fileCoverage.StmtCoverage, report.Threshold(fileCoverage.Name, opts.Threshold))
+			table.Rich(row, rowColors(color, len(row)))
+		} else {
+			table.Append(row)
+		}
 	}
 	table.SetAutoFormatHeaders(false)
-	table.SetFooter(makeRow(report.Total))
+	footer := makeRow(report, report.Total)
+	table.SetFooter(footer)
+	if opts.Color {
+		table.SetFooterColor(rowColors(CoverageColor(report.Total.StmtCoverage, opts.Threshold), len(footer))...)
+	}
 	table.Render()
 }
 
+// PrintMarkdown prints the report as a GitHub/GitLab flavored markdown table,
+// rows below their own or the non-zero default threshold are marked with an emoji
+func PrintMarkdown(report *Report, writer io.Writer, packages bool, threshold float64) {
+	header := makeHeader(report, packages)
+	writeMarkdownRow(writer, header)
+	fmt.Fprintf(writer, "| :--- |%s\n", strings.Repeat(" ---: |", len(header)-1))
+	for _, fileCoverage := range report.Files {
+		row := makeRow(report, fileCoverage)
+		row[0] = markdownName(fileCoverage, report.Threshold(fileCoverage.Name, threshold))
+		writeMarkdownRow(writer, row)
+	}
+	footer := makeRow(report, report.Total)
+	for i, cell := range footer {
+		footer[i] = "**" + escapeMarkdown(cell) + "**"
+	}
+	writeMarkdownRow(writer, footer)
+}
+
+func markdownName(c Summary, threshold float64) string {
+	name := escapeMarkdown(strings.TrimLeft(displayName(c), " "))
+	if threshold > 0 && c.StmtCoverage < threshold {
+		name = ":x: " + name
+	}
+	// Markdown collapses spaces, indent tree rows with non-breaking ones
+	return strings.Repeat("&nbsp;&nbsp;", c.Level) + name
+}
+
+func writeMarkdownRow(writer io.Writer, row []string) {
+	fmt.Fprintf(writer, "| %s |\n", strings.Join(row, " | "))
+}
+
+var markdownEscaper = strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_")
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+func makeHeader(report *Report, packages bool) []string {
+	item := report.Item
+	if item == "" {
+		item = itemName(packages)
+	}
+	header := []string{
+		item, "Blocks", "Missing", "Stmts", "Missing",
+		"Block cover %", "Stmt cover %"}
+	if report.WeakHits > 0 {
+		header = append(header, fmt.Sprintf("Weak stmts (<%d hits)", report.WeakHits))
+	}
+	return header
+}
+
 // Converts a Summary to a slice of string so that it
 // can be printed in the table
-func makeRow(c Summary) []string {
-	return []string{
-		c.Name,
+func makeRow(report *Report, c Summary) []string {
+	row := []string{
+		displayName(c),
 		fmt.Sprintf("%d", c.Blocks),
 		fmt.Sprintf("%d", c.MissingBlocks),
 		fmt.Sprintf("%d", c.Stmts),
 		fmt.Sprintf("%d", c.MissingStmts),
 		fmt.Sprintf("%.2f", c.BlockCoverage),
 		fmt.Sprintf("%.2f", c.StmtCoverage)}
+	if report.WeakHits > 0 {
+		row = append(row, fmt.Sprintf("%d", c.WeakStmts))
+	}
+	return row
+}
+
+// Indents rows of a tree report under their parent
+func displayName(c Summary) string {
+	if c.Level == 0 {
+		return c.Name
+	}
+	return strings.Repeat("  ", c.Level) + path.Base(c.Name)
 }
@@ -0,0 +1,51 @@
+package coverreport
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintMarkdown(t *testing.T) {
+	report := &Report{
+		Total: Summary{Name: "Total", Blocks: 4, Stmts: 10, MissingBlocks: 1, MissingStmts: 2, BlockCoverage: 75, StmtCoverage: 80},
+		Files: []Summary{
+			{Name: "pkg/a_b.go", Blocks: 2, Stmts: 5, BlockCoverage: 100, StmtCoverage: 100},
+			{Name: "pkg/c.go", Blocks: 2, Stmts: 5, MissingBlocks: 1, MissingStmts: 2, BlockCoverage: 50, StmtCoverage: 60},
+		},
+	}
+
+	var buf bytes.Buffer
+	PrintMarkdown(report, &buf, false, 70)
+	assert.Equal(t, `| File | Blocks | Missing | Stmts | Missing | Block cover % | Stmt cover % |
+| :--- | ---: | ---: | ---: | ---: | ---: | ---: |
+| pkg/a\_b.go | 2 | 0 | 5 | 0 | 100.00 | 100.00 |
+| :x: pkg/c.go | 2 | 1 | 5 | 2 | 50.00 | 60.00 |
+| **Total** | **4** | **1** | **10** | **2** | **75.00** | **80.00** |
+`, buf.String())
+}
+
+func TestPrintInvalidFormat(t *testing.T) {
+	assert.Error(t, Print(&Report{}, &bytes.Buffer{}, PrintOptions{Format: "xml"}))
+}
+
+func TestPrintCSV(t *testing.T) {
+	report := &Report{
+		Total: Summary{Name: "Total", Blocks: 3, Stmts: 3, MissingBlocks: 1, MissingStmts: 1, BlockCoverage: 200.0 / 3, StmtCoverage: 200.0 / 3},
+		Files: []Summary{
+			{Name: "a, b.go", Blocks: 3, Stmts: 3, MissingBlocks: 1, MissingStmts: 1, BlockCoverage: 200.0 / 3, StmtCoverage: 200.0 / 3},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, PrintCSV(report, &buf, ','))
+	assert.Equal(t, `name,blocks,stmts,missing_blocks,missing_stmts,block_coverage,stmt_coverage
+"a, b.go",3,3,1,1,66.66666666666667,66.66666666666667
+Total,3,3,1,1,66.66666666666667,66.66666666666667
+`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, Print(report, &buf, PrintOptions{Format: FormatTSV}))
+	assert.Contains(t, buf.String(), "a, b.go\t3\t3\t1\t1\t66.66666666666667\t66.66666666666667\n")
+}
@@ -2,6 +2,7 @@ package covertool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -11,23 +12,78 @@ import (
 	"gopkg.in/guregu/null.v4"
 )
 
+// TokenType is the kind of token used to authenticate to GitLab.
+type TokenType string
+
+const (
+	TokenTypePrivate TokenType = "private"
+	TokenTypeJob     TokenType = "job"
+	TokenTypeOAuth   TokenType = "oauth"
+)
+
 type Tool struct {
 	projectID string
+	tokenType TokenType
 	cli       *gitlab.Client
 }
 
-func New(baseURL, token, projectID string) (*Tool, error) {
-	client, err := gitlab.NewClient(token, gitlab.WithBaseURL(baseURL))
+func New(baseURL, token, projectID string, opts ...Option) (*Tool, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	httpClient, err := o.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	clientOpts := []gitlab.ClientOptionFunc{
+		gitlab.WithBaseURL(baseURL),
+		gitlab.WithHTTPClient(httpClient),
+		// Retries are handled by the http client built from options
+		gitlab.WithoutRetries(),
+	}
+
+	var client *gitlab.Client
+	switch o.tokenType {
+	case TokenTypePrivate:
+		client, err = gitlab.NewClient(token, clientOpts...)
+	case TokenTypeJob:
+		client, err = gitlab.NewJobClient(token, clientOpts...)
+	case TokenTypeOAuth:
+		client, err = gitlab.NewOAuthClient(token, clientOpts...)
+	default:
+		return nil, fmt.Errorf("token type must be one of private, job or oauth, got %q", o.tokenType)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	return &Tool{
 		projectID: projectID,
+		tokenType: o.tokenType,
 		cli:       client,
 	}, nil
 }
 
+// explainError hints at missing permissions when GitLab rejects the token.
+func (t *Tool) explainError(err error) error {
+	var errResp *gitlab.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return err
+	}
+
+	switch errResp.Response.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		if t.tokenType == TokenTypeJob {
+			return fmt.Errorf("%w (job tokens are not allowed to access commit statuses, use a private or oauth token)", err)
+		}
+		return fmt.Errorf("%w (%s token needs api scope and at least developer role to access commit statuses)", err, t.tokenType)
+	}
+	return err
+}
+
 func (t *Tool) getLatestCommitFromRef(ctx context.Context, ref string) (string, error) {
 	commit, _, err := t.cli.Commits.GetCommit(t.projectID, ref, gitlab.WithContext(ctx))
 	if err != nil {
@@ -72,19 +128,54 @@ func (t *Tool) GetCommitStatuses(pid, sha string, opt *gitlab.GetCommitStatusesO
 	return cs, resp, err
 }
 
-func (t *Tool) Read(ctx context.Context, pipeline, ref string) (coverage null.Float, err error) {
-	sha, err := t.getLatestCommitFromRef(ctx, ref)
+// Baseline is the coverage read from the commit statuses of a ref.
+type Baseline struct {
+	// SHA is the commit the coverage was read from.
+	SHA string `json:"sha"`
+	// Depth is the number of parent commits walked from the tip of the ref.
+	Depth    int        `json:"depth"`
+	Coverage null.Float `json:"coverage"`
+}
+
+// Read reads the coverage of pipeline from the latest commit of ref. If the
+// commit has no coverage status, up to maxParents first parents are walked
+// until a commit with coverage is found.
+func (t *Tool) Read(ctx context.Context, pipeline, ref string, maxParents int) (*Baseline, error) {
+	commit, _, err := t.cli.Commits.GetCommit(t.projectID, ref, gitlab.WithContext(ctx))
 	if err != nil {
-		return coverage, fmt.Errorf("error get latest commit hash from %q: %w", ref, err)
+		return nil, fmt.Errorf("error get latest commit hash from %q: %w", ref, t.explainError(err))
 	}
 
+	baseline := &Baseline{SHA: commit.ID}
+	for {
+		baseline.Coverage, err = t.readCommitCoverage(ctx, pipeline, commit.ID)
+		if err != nil {
+			return nil, err
+		}
+		if baseline.Coverage.Valid || baseline.Depth >= maxParents || len(commit.ParentIDs) == 0 {
+			break
+		}
+
+		parent := commit.ParentIDs[0]
+		commit, _, err = t.cli.Commits.GetCommit(t.projectID, parent, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("error get parent commit %q: %w", parent, t.explainError(err))
+		}
+		baseline.SHA = commit.ID
+		baseline.Depth++
+	}
+
+	return baseline, nil
+}
+
+func (t *Tool) readCommitCoverage(ctx context.Context, pipeline, sha string) (coverage null.Float, err error) {
 	statusList, _, err := t.GetCommitStatuses(
 		t.projectID, sha, &gitlab.GetCommitStatusesOptions{
 			Name: &pipeline,
 			All:  gitlab.Bool(true),
 		}, gitlab.WithContext(ctx))
 	if err != nil {
-		return coverage, fmt.Errorf("error get commit status: %w", err)
+		return coverage, fmt.Errorf("error get commit status: %w", t.explainError(err))
 	}
 
 	for _, status := range statusList {
@@ -103,7 +194,7 @@ func (t *Tool) Write(ctx context.Context, pipeline, ref, optionalSha string, cov
 	if optionalSha == "" {
 		optionalSha, err = t.getLatestCommitFromRef(ctx, ref)
 		if err != nil {
-			return fmt.Errorf("error get latest commit hash from %q: %w", ref, err)
+			return fmt.Errorf("error get latest commit hash from %q: %w", ref, t.explainError(err))
 		}
 	}
 	_, _, err = t.cli.Commits.SetCommitStatus(
@@ -114,7 +205,7 @@ func (t *Tool) Write(ctx context.Context, pipeline, ref, optionalSha string, cov
 			Coverage: &coverage,
 		}, gitlab.WithContext(ctx))
 	if err != nil {
-		return fmt.Errorf("error set commit status: %w", err)
+		return fmt.Errorf("error set commit status: %w", t.explainError(err))
 	}
 	return nil
 }
@@ -0,0 +1,153 @@
+package covertool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/guregu/null.v4"
+
+	"github.com/timonwong/alauda-pipeline-cover/testdata"
+)
+
+func newTestTool(t *testing.T, gitlab *testdata.GitLab, token string, opts ...Option) *Tool {
+	opts = append([]Option{WithRetries(0, time.Millisecond, time.Millisecond)}, opts...)
+	tool, err := New(gitlab.APIBase(), token, "42", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tool
+}
+
+func TestRead(t *testing.T) {
+	gitlab := testdata.NewGitLab("secret")
+	defer gitlab.Close()
+	gitlab.AddCommit("c1", nil)
+	gitlab.AddCommit("c2", []string{"c1"})
+	gitlab.AddCommit("c3", []string{"c2"}, "main")
+	gitlab.AddStatus("c1", "cover", 75)
+	gitlab.AddStatus("c1", "cover", 80)
+	gitlab.AddStatus("c2", "other", 90)
+
+	tool := newTestTool(t, gitlab, "secret")
+	baseline, err := tool.Read(context.Background(), "cover", "main", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &Baseline{SHA: "c3"}, baseline)
+
+	// The largest coverage of the first commit with one is read
+	baseline, err = tool.Read(context.Background(), "cover", "main", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, &Baseline{SHA: "c1", Depth: 2, Coverage: null.FloatFrom(80)}, baseline)
+
+	requests := gitlab.Requests()
+	last := requests[len(requests)-1]
+	assert.Equal(t, "projects/42/repository/commits/c1/statuses", last.Path)
+	assert.Equal(t, "cover", last.Query.Get("name"))
+	assert.Equal(t, "true", last.Query.Get("all"))
+
+	_, err = tool.Read(context.Background(), "cover", "nosuchref", 0)
+	assert.EqualError(t, err, `error get latest commit hash from "nosuchref": GET `+
+		gitlab.APIBase()+`/projects/42/repository/commits/nosuchref: 404 {message: 404 Commit Not Found}`)
+}
+
+func TestReadMaxParents(t *testing.T) {
+	gitlab := testdata.NewGitLab("secret")
+	defer gitlab.Close()
+	gitlab.AddCommit("c1", nil)
+	gitlab.AddCommit("c2", []string{"c1"})
+	gitlab.AddCommit("c3", []string{"c2", "m1"}, "main")
+	gitlab.AddCommit("m1", nil)
+	gitlab.AddStatus("m1", "cover", 90)
+
+	tool := newTestTool(t, gitlab, "secret")
+	for _, tc := range []struct {
+		maxParents int
+		want       *Baseline
+	}{
+		// The walk stops at maxParents
+		{maxParents: 1, want: &Baseline{SHA: "c2", Depth: 1}},
+		// Or at the root commit, second parents are never walked
+		{maxParents: 5, want: &Baseline{SHA: "c1", Depth: 2}},
+	} {
+		baseline, err := tool.Read(context.Background(), "cover", "main", tc.maxParents)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, baseline, tc.maxParents)
+	}
+
+	gitlab.AddStatus("c2", "cover", 70)
+	baseline, err := tool.Read(context.Background(), "cover", "main", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, &Baseline{SHA: "c2", Depth: 1, Coverage: null.FloatFrom(70)}, baseline)
+
+	// A missing parent fails the walk
+	gitlab.AddCommit("c4", []string{"gone"}, "broken")
+	_, err = tool.Read(context.Background(), "cover", "broken", 5)
+	assert.Contains(t, err.Error(), `error get parent commit "gone"`)
+}
+
+func TestWrite(t *testing.T) {
+	gitlab := testdata.NewGitLab("secret")
+	defer gitlab.Close()
+	gitlab.AddCommit("c1", nil, "main")
+
+	tool := newTestTool(t, gitlab, "secret")
+	assert.NoError(t, tool.Write(context.Background(), "cover", "main", "", 81.5))
+	assert.NoError(t, tool.Write(context.Background(), "cover", "main", "c0", 79))
+
+	coverage := 81.5
+	assert.Equal(t, []testdata.CommitStatus{
+		{SHA: "c1", Ref: "main", Name: "cover", Status: "success", Coverage: &coverage},
+	}, gitlab.Statuses("c1"))
+	assert.Len(t, gitlab.Statuses("c0"), 1)
+
+	var methods []string
+	for _, r := range gitlab.Requests() {
+		methods = append(methods, r.Method+" "+r.Path)
+	}
+	assert.Equal(t, []string{
+		"GET projects/42/repository/commits/main",
+		"POST projects/42/statuses/c1",
+		"POST projects/42/statuses/c0",
+	}, methods)
+}
+
+func TestExplainError(t *testing.T) {
+	gitlab := testdata.NewGitLab("secret")
+	defer gitlab.Close()
+	gitlab.AddCommit("c1", nil, "main")
+
+	tool := newTestTool(t, gitlab, "wrong")
+	_, err := tool.Read(context.Background(), "cover", "main", 0)
+	assert.Contains(t, err.Error(), "401 {message: 401 Unauthorized} (private token needs api scope")
+
+	tool = newTestTool(t, gitlab, "wrong", WithTokenType(TokenTypeJob))
+	err = tool.Write(context.Background(), "cover", "main", "c1", 80)
+	assert.Contains(t, err.Error(), "(job tokens are not allowed to access commit statuses")
+
+	// Every token type is sent the way GitLab expects
+	for _, tokenType := range []TokenType{TokenTypePrivate, TokenTypeJob, TokenTypeOAuth} {
+		tool = newTestTool(t, gitlab, "secret", WithTokenType(tokenType))
+		_, err = tool.Read(context.Background(), "cover", "main", 0)
+		assert.NoError(t, err, tokenType)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	gitlab := testdata.NewGitLab("secret")
+	defer gitlab.Close()
+	gitlab.AddCommit("c1", nil, "main")
+	gitlab.AddStatus("c1", "cover", 80)
+
+	gitlab.FailNext(2)
+	tool := newTestTool(t, gitlab, "secret", WithRetries(2, time.Millisecond, time.Millisecond))
+	baseline, err := tool.Read(context.Background(), "cover", "main", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, null.FloatFrom(80), baseline.Coverage)
+	assert.Len(t, gitlab.Requests(), 4)
+
+	gitlab.FailNext(2)
+	tool = newTestTool(t, gitlab, "secret", WithRetries(1, time.Millisecond, time.Millisecond))
+	_, err = tool.Read(context.Background(), "cover", "main", 0)
+	assert.Contains(t, err.Error(), "503 {message: 503 Service Unavailable}")
+}
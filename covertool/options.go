@@ -0,0 +1,202 @@
+package covertool
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// Option configures the GitLab client used by a Tool.
+type Option func(*options)
+
+type options struct {
+	tokenType TokenType
+
+	timeout      time.Duration
+	retries      int
+	retryWaitMin time.Duration
+	retryWaitMax time.Duration
+
+	caFile             string
+	certFile, keyFile  string
+	proxyURL           string
+	insecureSkipVerify bool
+}
+
+func defaultOptions() *options {
+	return &options{
+		tokenType:    TokenTypePrivate,
+		timeout:      30 * time.Second,
+		retries:      3,
+		retryWaitMin: time.Second,
+		retryWaitMax: 30 * time.Second,
+	}
+}
+
+// WithTokenType sets how the token is sent to GitLab, defaults to TokenTypePrivate.
+func WithTokenType(tokenType TokenType) Option {
+	return func(o *options) {
+		o.tokenType = tokenType
+	}
+}
+
+// WithTimeout sets the timeout of every single request attempt, zero means no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetries sets how many times a request failed with connection errors,
+// 429 or 5xx is retried, waiting with exponential backoff between waitMin
+// and waitMax.
+func WithRetries(retries int, waitMin, waitMax time.Duration) Option {
+	return func(o *options) {
+		o.retries = retries
+		o.retryWaitMin = waitMin
+		o.retryWaitMax = waitMax
+	}
+}
+
+// WithCACert trusts the PEM encoded certificates in caFile in addition to the system pool.
+func WithCACert(caFile string) Option {
+	return func(o *options) {
+		o.caFile = caFile
+	}
+}
+
+// WithClientCert authenticates to the server with the PEM encoded key pair for mutual TLS.
+func WithClientCert(certFile, keyFile string) Option {
+	return func(o *options) {
+		o.certFile = certFile
+		o.keyFile = keyFile
+	}
+}
+
+// WithProxy sends requests through proxyURL instead of the proxy from environment.
+func WithProxy(proxyURL string) Option {
+	return func(o *options) {
+		o.proxyURL = proxyURL
+	}
+}
+
+// WithInsecureSkipVerify disables verification of the server certificate.
+func WithInsecureSkipVerify(insecureSkipVerify bool) Option {
+	return func(o *options) {
+		o.insecureSkipVerify = insecureSkipVerify
+	}
+}
+
+func (o *options) httpClient() (*http.Client, error) {
+	transport, err := o.transport()
+	if err != nil {
+		return nil, err
+	}
+
+	client := retryablehttp.NewClient()
+	client.HTTPClient = &http.Client{
+		Transport: transport,
+		Timeout:   o.timeout,
+	}
+	client.Logger = nil
+	client.RetryMax = o.retries
+	client.RetryWaitMin = o.retryWaitMin
+	client.RetryWaitMax = o.retryWaitMax
+	client.Backoff = backoff
+	// Let go-gitlab turn the last failed response into an error
+	client.ErrorHandler = retryablehttp.PassthroughErrorHandler
+	return client.StandardClient(), nil
+}
+
+func (o *options) transport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.insecureSkipVerify, // nolint: gosec
+	}
+
+	if o.caFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(o.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ca file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in ca file %q", o.caFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	if o.certFile != "" || o.keyFile != "" {
+		if o.certFile == "" || o.keyFile == "" {
+			return nil, errors.New("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if o.proxyURL != "" {
+		proxy, err := url.Parse(o.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	return transport, nil
+}
+
+const headerRateReset = "RateLimit-Reset"
+
+// backoff waits as long as the server asked to via the Retry-After or
+// RateLimit-Reset headers but no longer than max, and falls back to
+// exponential backoff otherwise.
+func backoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if wait, ok := retryAfter(resp.Header, time.Now()); ok {
+			if wait > max {
+				return max
+			}
+			return wait
+		}
+	}
+	return retryablehttp.DefaultBackoff(min, max, attemptNum, resp)
+}
+
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	if v := header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return nonNegative(at.Sub(now)), true
+		}
+	}
+	if v := header.Get(headerRateReset); v != "" {
+		if reset, err := strconv.ParseInt(v, 10, 64); err == nil && reset > 0 {
+			return nonNegative(time.Unix(reset, 0).Sub(now)), true
+		}
+	}
+	return 0, false
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
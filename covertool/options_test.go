@@ -0,0 +1,196 @@
+package covertool
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		wait   time.Duration
+		ok     bool
+	}{
+		{name: "none", header: http.Header{}},
+		{name: "seconds", header: http.Header{"Retry-After": {"120"}}, wait: 2 * time.Minute, ok: true},
+		{name: "zero seconds", header: http.Header{"Retry-After": {"0"}}, ok: true},
+		{name: "negative seconds", header: http.Header{"Retry-After": {"-5"}}},
+		{name: "http date", header: http.Header{"Retry-After": {"Tue, 01 Mar 2022 12:00:30 GMT"}}, wait: 30 * time.Second, ok: true},
+		{name: "past http date", header: http.Header{"Retry-After": {"Tue, 01 Mar 2022 11:00:00 GMT"}}, ok: true},
+		{name: "invalid", header: http.Header{"Retry-After": {"soon"}}},
+		{name: "rate limit reset", header: http.Header{"Ratelimit-Reset": {"1646136045"}}, wait: 45 * time.Second, ok: true},
+		{name: "past rate limit reset", header: http.Header{"Ratelimit-Reset": {"1646135000"}}, ok: true},
+		{name: "negative rate limit reset", header: http.Header{"Ratelimit-Reset": {"-1"}}},
+		{
+			name:   "retry after first",
+			header: http.Header{"Retry-After": {"10"}, "Ratelimit-Reset": {"1646136045"}},
+			wait:   10 * time.Second, ok: true,
+		},
+	} {
+		wait, ok := retryAfter(tc.header, now)
+		assert.Equal(t, tc.ok, ok, tc.name)
+		assert.Equal(t, tc.wait, wait, tc.name)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	resp := func(code int, retryAfter string) *http.Response {
+		header := http.Header{}
+		if retryAfter != "" {
+			header.Set("Retry-After", retryAfter)
+		}
+		return &http.Response{StatusCode: code, Header: header}
+	}
+
+	assert.Equal(t, 3*time.Second, backoff(time.Second, time.Minute, 0, resp(http.StatusTooManyRequests, "3")))
+	assert.Equal(t, 3*time.Second, backoff(time.Second, time.Minute, 0, resp(http.StatusServiceUnavailable, "3")))
+	// Waits asked by the server are clamped
+	assert.Equal(t, time.Minute, backoff(time.Second, time.Minute, 0, resp(http.StatusTooManyRequests, "3600")))
+	// Other responses back off exponentially
+	assert.Equal(t, 4*time.Second, backoff(time.Second, time.Minute, 2, resp(http.StatusBadGateway, "3")))
+	assert.Equal(t, 4*time.Second, backoff(time.Second, time.Minute, 2, resp(http.StatusTooManyRequests, "")))
+}
+
+// Writes a self-signed client certificate and its key as PEM files
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = writePEM(t, filepath.Join(dir, "client.pem"), "CERTIFICATE", der)
+	keyFile = writePEM(t, filepath.Join(dir, "client-key.pem"), "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, filename, blockType string, der []byte) string {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// Returns the status code of a GET request sent by a client built from opts
+func get(url string, opts ...Option) (int, error) {
+	o := defaultOptions()
+	o.retries = 0
+	for _, opt := range opts {
+		opt(o)
+	}
+	client, err := o.httpClient()
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestTransportTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	dir := t.TempDir()
+	caFile := writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ts.Certificate().Raw)
+
+	_, err := get(ts.URL)
+	assert.Error(t, err, "server certificate is not trusted")
+
+	code, err := get(ts.URL, WithCACert(caFile))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, err = get(ts.URL, WithInsecureSkipVerify(true))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, err = get(ts.URL, WithCACert(filepath.Join(dir, "nosuch.pem")))
+	assert.Contains(t, err.Error(), "unable to read ca file")
+
+	notPEM := filepath.Join(dir, "ca.txt")
+	assert.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+	_, err = get(ts.URL, WithCACert(notPEM))
+	assert.EqualError(t, err, fmt.Sprintf("no certificate found in ca file %q", notPEM))
+}
+
+func TestTransportClientCert(t *testing.T) {
+	var clientName string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientName = r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+	dir := t.TempDir()
+	certFile, keyFile := writeClientCert(t, dir)
+
+	_, err := get(ts.URL, WithInsecureSkipVerify(true))
+	assert.Error(t, err, "client certificate is required")
+
+	code, err := get(ts.URL, WithInsecureSkipVerify(true), WithClientCert(certFile, keyFile))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "client", clientName)
+
+	_, err = get(ts.URL, WithClientCert(certFile, ""))
+	assert.EqualError(t, err, "client certificate and key must be set together")
+
+	_, err = get(ts.URL, WithClientCert(certFile, filepath.Join(dir, "nosuch.pem")))
+	assert.Contains(t, err.Error(), "unable to load client certificate")
+
+	// The key must match the certificate
+	_, otherKey := writeClientCert(t, t.TempDir())
+	_, err = get(ts.URL, WithClientCert(certFile, otherKey))
+	assert.Contains(t, err.Error(), "unable to load client certificate")
+}
+
+func TestTransportProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer proxy.Close()
+
+	code, err := get("http://gitlab.invalid/api/v4/projects", WithProxy(proxy.URL))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTeapot, code)
+	assert.Equal(t, "http://gitlab.invalid/api/v4/projects", proxied)
+
+	_, err = get("http://gitlab.invalid", WithProxy("://proxy"))
+	assert.Contains(t, err.Error(), "invalid proxy url")
+}
@@ -3,6 +3,8 @@ module github.com/timonwong/alauda-pipeline-cover
 go 1.16
 
 require (
+	github.com/hashicorp/go-retryablehttp v0.7.0
+	github.com/klauspost/compress v1.15.0
 	github.com/mattn/go-zglob v0.0.3
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/spf13/cobra v1.3.0
@@ -11,10 +13,10 @@ require (
 	github.com/xanzy/go-gitlab v0.56.0
 	golang.org/x/tools v0.1.9
 	gopkg.in/guregu/null.v4 v4.0.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/hashicorp/go-retryablehttp v0.7.0 // indirect
 	github.com/magiconair/properties v1.8.6 // indirect
 	github.com/spf13/afero v1.8.1 // indirect
 	github.com/spf13/pflag v1.0.5
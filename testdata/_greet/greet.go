@@ -0,0 +1,44 @@
+package greet
+
+import (
+	"errors"
+	"strings"
+)
+
+// Greet greets name in a language.
+func Greet(name, lang string) (string, error) {
+	if name == "" {
+		return "", errors.New("empty name")
+	}
+	var greeting string
+	switch lang {
+	case "en":
+		greeting = "Hello"
+	case "fr":
+		greeting = "Bonjour"
+	default:
+		return "", errors.New("unknown language")
+	}
+	return greeting + ", " + name, nil
+}
+
+// Shout upper cases every word longer than min.
+func Shout(words []string, min int) []string {
+	out := make([]string, 0, len(words))
+	for _, w := range words {
+		if len(w) <= min {
+			out = append(out, w)
+			continue
+		} else if w == "" {
+			panic("unreachable")
+		}
+		out = append(out, strings.ToUpper(w))
+	}
+	f := func(s string) string {
+		return s + "!"
+	}
+	if len(out) > 0 {
+		out[0] = f(out[0])
+	}
+	return out
+}
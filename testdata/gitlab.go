@@ -0,0 +1,188 @@
+package testdata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Commit is a commit served by GitLab.
+type Commit struct {
+	ID        string   `json:"id"`
+	ParentIDs []string `json:"parent_ids"`
+}
+
+// CommitStatus is a commit status served and recorded by GitLab.
+type CommitStatus struct {
+	SHA      string   `json:"sha"`
+	Ref      string   `json:"ref,omitempty"`
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Coverage *float64 `json:"coverage"`
+}
+
+// Request is a request received by GitLab.
+type Request struct {
+	Method string
+	// Path is relative to the api base and still escaped
+	Path  string
+	Query url.Values
+}
+
+// GitLab is a fake GitLab API serving the commits and commit statuses
+// endpoints of projects.
+type GitLab struct {
+	*httptest.Server
+	// Token every request must authenticate with
+	Token string
+
+	mu       sync.Mutex
+	commits  map[string]Commit
+	statuses map[string][]CommitStatus
+	requests []Request
+	failures int
+}
+
+// NewGitLab starts a fake GitLab accepting token, it must be closed.
+func NewGitLab(token string) *GitLab {
+	g := &GitLab{
+		Token:    token,
+		commits:  make(map[string]Commit),
+		statuses: make(map[string][]CommitStatus),
+	}
+	g.Server = httptest.NewServer(http.HandlerFunc(g.serve))
+	return g
+}
+
+// APIBase returns the base url of the api.
+func (g *GitLab) APIBase() string {
+	return g.URL + "/api/v4"
+}
+
+// AddCommit adds a commit which can also be got by the given refs.
+func (g *GitLab) AddCommit(sha string, parents []string, refs ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	commit := Commit{ID: sha, ParentIDs: parents}
+	g.commits[sha] = commit
+	for _, ref := range refs {
+		g.commits[ref] = commit
+	}
+}
+
+// AddStatus adds a status with coverage to a commit.
+func (g *GitLab) AddStatus(sha, name string, coverage float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.statuses[sha] = append(g.statuses[sha], CommitStatus{SHA: sha, Name: name, Status: "success", Coverage: &coverage})
+}
+
+// Statuses returns the statuses of a commit.
+func (g *GitLab) Statuses(sha string) []CommitStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]CommitStatus(nil), g.statuses[sha]...)
+}
+
+// Requests returns the requests received so far.
+func (g *GitLab) Requests() []Request {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Request(nil), g.requests...)
+}
+
+// FailNext makes the next n requests fail with 503 Service Unavailable.
+func (g *GitLab) FailNext(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = n
+}
+
+func (g *GitLab) serve(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4/")
+	// Clients probe the api base for rate limit headers before the first call
+	if path == "" {
+		writeJSON(w, http.StatusOK, map[string]string{})
+		return
+	}
+	g.requests = append(g.requests, Request{Method: r.Method, Path: path, Query: r.URL.Query()})
+	if g.failures > 0 {
+		g.failures--
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"message": "503 Service Unavailable"})
+		return
+	}
+	if !g.authenticated(r) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "401 Unauthorized"})
+		return
+	}
+
+	// projects/:id/repository/commits/:sha[/statuses] or projects/:id/statuses/:sha
+	parts := strings.Split(path, "/")
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 5 && parts[2] == "repository" && parts[3] == "commits":
+		commit, ok := g.commits[unescape(parts[4])]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "404 Commit Not Found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, commit)
+	case r.Method == http.MethodGet && len(parts) == 6 && parts[2] == "repository" && parts[5] == "statuses":
+		name := r.URL.Query().Get("name")
+		statuses := make([]CommitStatus, 0)
+		for _, status := range g.statuses[unescape(parts[4])] {
+			if name == "" || status.Name == name {
+				statuses = append(statuses, status)
+			}
+		}
+		writeJSON(w, http.StatusOK, statuses)
+	case r.Method == http.MethodPost && len(parts) == 4 && parts[2] == "statuses":
+		var opts struct {
+			State    string   `json:"state"`
+			Ref      string   `json:"ref"`
+			Name     string   `json:"name"`
+			Coverage *float64 `json:"coverage"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		sha := unescape(parts[3])
+		status := CommitStatus{SHA: sha, Ref: opts.Ref, Name: opts.Name, Status: opts.State, Coverage: opts.Coverage}
+		g.statuses[sha] = append(g.statuses[sha], status)
+		writeJSON(w, http.StatusCreated, status)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "404 Not Found"})
+	}
+}
+
+func (g *GitLab) authenticated(r *http.Request) bool {
+	for _, token := range []string{
+		r.Header.Get("PRIVATE-TOKEN"),
+		r.Header.Get("JOB-TOKEN"),
+		strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "),
+	} {
+		if token == g.Token {
+			return true
+		}
+	}
+	return false
+}
+
+func unescape(s string) string {
+	if unescaped, err := url.PathUnescape(s); err == nil {
+		return unescaped
+	}
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v) // nolint: errcheck
+}